@@ -0,0 +1,586 @@
+// Package btree implements a disk-backed B+Tree index on top of the
+// engine package's page primitives and copy-on-write Tx API: internal
+// nodes and leaves are both PageTypeIndex pages (node.go), and leaf
+// values too large to inline spill into PageTypeOverflow chains
+// (overflow.go). Every mutation runs inside one writable Tx and copies
+// every node on the root-to-leaf path to a new page rather than rewriting
+// an existing one in place, mirroring the COW discipline Tx already
+// enforces for every other writer.
+package btree
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/Rudra-Sankha-Sinhamahapatra/Anon-DB/engine"
+)
+
+// ErrKeyNotFound is returned by Get and Delete when the key isn't present.
+var ErrKeyNotFound = errors.New("btree: key not found")
+
+// BTree is a B+Tree index stored in df, rooted at df's RootPage (0 means
+// empty). Open does not create anything; the first Put establishes a root.
+type BTree struct {
+	df *engine.DBFile
+}
+
+// Open returns the B+Tree already rooted at df's current RootPage (or an
+// empty tree, if it's 0).
+func Open(df *engine.DBFile) (*BTree, error) {
+	return &BTree{df: df}, nil
+}
+
+// promotion is what a split returns to its caller: a new separator key and
+// the page to its right, to be inserted into the parent alongside the
+// (possibly also new) page number for the subtree that split.
+type promotion struct {
+	key       []byte
+	rightPage uint32
+}
+
+// childIndexFor returns the index into n.children that key's subtree is
+// reachable through: the standard B+Tree invariant is that n.keys[i] is
+// the smallest key reachable through n.children[i+1].
+func childIndexFor(n *node, key []byte) int {
+	idx := 0
+	for idx < len(n.keys) && bytes.Compare(key, n.keys[idx]) >= 0 {
+		idx++
+	}
+	return idx
+}
+
+func (t *BTree) readNode(tx *engine.Tx, pageNum uint32) (*node, error) {
+	page, err := tx.GetPage(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	usedLen := (engine.PageSize - engine.PageHeaderSize) - int(page.GetFreeSpace())
+	buf, err := page.ReadData(0, uint16(usedLen))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeNode(buf, page.GetPageNum(), page.NextPage())
+}
+
+func (t *BTree) persistLeaf(tx *engine.Tx, n *node) (uint32, error) {
+	page, err := tx.AllocatePage(engine.PageTypeIndex)
+	if err != nil {
+		return 0, err
+	}
+	n.pageNum = page.GetPageNum()
+	page.SetNextPage(n.nextLeaf)
+
+	buf, err := encodeNode(n)
+	if err != nil {
+		return 0, err
+	}
+	if err := page.WriteData(0, buf); err != nil {
+		return 0, err
+	}
+	return page.GetPageNum(), nil
+}
+
+func (t *BTree) persistInternal(tx *engine.Tx, n *node) (uint32, error) {
+	page, err := tx.AllocatePage(engine.PageTypeIndex)
+	if err != nil {
+		return 0, err
+	}
+	n.pageNum = page.GetPageNum()
+
+	buf, err := encodeNode(n)
+	if err != nil {
+		return 0, err
+	}
+	if err := page.WriteData(0, buf); err != nil {
+		return 0, err
+	}
+	return page.GetPageNum(), nil
+}
+
+// writeLeaf persists n, splitting it across two pages first if it no
+// longer fits in one.
+func (t *BTree) writeLeaf(tx *engine.Tx, n *node) (uint32, *promotion, error) {
+	if n.encodedSize() <= nodeCapacity {
+		pageNum, err := t.persistLeaf(tx, n)
+		return pageNum, nil, err
+	}
+
+	mid := len(n.entries) / 2
+	left := newLeaf(0)
+	left.entries = n.entries[:mid]
+	right := newLeaf(0)
+	right.entries = n.entries[mid:]
+	right.nextLeaf = n.nextLeaf
+
+	rightPageNum, err := t.persistLeaf(tx, right)
+	if err != nil {
+		return 0, nil, err
+	}
+	left.nextLeaf = rightPageNum
+
+	leftPageNum, err := t.persistLeaf(tx, left)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return leftPageNum, &promotion{key: right.entries[0].key, rightPage: rightPageNum}, nil
+}
+
+// writeInternal persists n, splitting it across two pages first if it no
+// longer fits in one. The middle key is removed from both halves and
+// promoted to the caller, the usual internal-node split.
+func (t *BTree) writeInternal(tx *engine.Tx, n *node) (uint32, *promotion, error) {
+	if n.encodedSize() <= nodeCapacity {
+		pageNum, err := t.persistInternal(tx, n)
+		return pageNum, nil, err
+	}
+
+	mid := len(n.keys) / 2
+	left := newInternal(0)
+	left.keys = n.keys[:mid]
+	left.children = n.children[:mid+1]
+
+	sepKey := n.keys[mid]
+
+	right := newInternal(0)
+	right.keys = n.keys[mid+1:]
+	right.children = n.children[mid+1:]
+
+	rightPageNum, err := t.persistInternal(tx, right)
+	if err != nil {
+		return 0, nil, err
+	}
+	leftPageNum, err := t.persistInternal(tx, left)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return leftPageNum, &promotion{key: sepKey, rightPage: rightPageNum}, nil
+}
+
+func (t *BTree) makeEntry(tx *engine.Tx, key, value []byte) (entry, error) {
+	if len(value) <= inlineValueThreshold {
+		return entry{key: key, value: value, valueLen: uint32(len(value))}, nil
+	}
+	head, err := writeOverflow(tx, value)
+	if err != nil {
+		return entry{}, err
+	}
+	return entry{key: key, overflow: head, valueLen: uint32(len(value))}, nil
+}
+
+func (t *BTree) entryValue(tx *engine.Tx, e entry) ([]byte, error) {
+	if e.overflow == 0 {
+		return e.value, nil
+	}
+	return readOverflow(tx, e.overflow, e.valueLen)
+}
+
+func (t *BTree) freeOverflowChain(tx *engine.Tx, head uint32) error {
+	for pageNum := head; pageNum != 0; {
+		page, err := tx.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+		next := page.NextPage()
+		if err := tx.FreePage(pageNum); err != nil {
+			return err
+		}
+		pageNum = next
+	}
+	return nil
+}
+
+// Get looks up key and returns its value, or ErrKeyNotFound.
+func (t *BTree) Get(key []byte) ([]byte, error) {
+	tx, err := t.df.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Commit()
+
+	root := tx.RootPage()
+	if root == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	pageNum := root
+	for {
+		n, err := t.readNode(tx, pageNum)
+		if err != nil {
+			return nil, err
+		}
+		if n.isLeaf() {
+			idx := sort.Search(len(n.entries), func(i int) bool {
+				return bytes.Compare(n.entries[i].key, key) >= 0
+			})
+			if idx >= len(n.entries) || !bytes.Equal(n.entries[idx].key, key) {
+				return nil, ErrKeyNotFound
+			}
+			return t.entryValue(tx, n.entries[idx])
+		}
+		pageNum = n.children[childIndexFor(n, key)]
+	}
+}
+
+// insertLeaf inserts key/value into the decoded leaf n, overwriting any
+// existing entry for key (freeing its old overflow chain first, if any).
+func (t *BTree) insertLeaf(tx *engine.Tx, n *node, key, value []byte) (uint32, *promotion, error) {
+	e, err := t.makeEntry(tx, key, value)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	idx := sort.Search(len(n.entries), func(i int) bool {
+		return bytes.Compare(n.entries[i].key, key) >= 0
+	})
+	if idx < len(n.entries) && bytes.Equal(n.entries[idx].key, key) {
+		if n.entries[idx].overflow != 0 {
+			if err := t.freeOverflowChain(tx, n.entries[idx].overflow); err != nil {
+				return 0, nil, err
+			}
+		}
+		n.entries[idx] = e
+	} else {
+		n.entries = append(n.entries, entry{})
+		copy(n.entries[idx+1:], n.entries[idx:])
+		n.entries[idx] = e
+	}
+
+	return t.writeLeaf(tx, n)
+}
+
+// insert descends from pageNum to insert key/value, copying every node on
+// the path to a new page, and returns the (possibly new) page number for
+// this subtree plus a promotion if this level split.
+func (t *BTree) insert(tx *engine.Tx, pageNum uint32, key, value []byte) (uint32, *promotion, error) {
+	n, err := t.readNode(tx, pageNum)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if n.isLeaf() {
+		return t.insertLeaf(tx, n, key, value)
+	}
+
+	idx := childIndexFor(n, key)
+	childPage := n.children[idx]
+
+	newChildPage, promoted, err := t.insert(tx, childPage, key, value)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := tx.FreePage(childPage); err != nil {
+		return 0, nil, err
+	}
+	n.children[idx] = newChildPage
+
+	if promoted != nil {
+		n.keys = append(n.keys, nil)
+		copy(n.keys[idx+1:], n.keys[idx:])
+		n.keys[idx] = promoted.key
+
+		n.children = append(n.children, 0)
+		copy(n.children[idx+2:], n.children[idx+1:])
+		n.children[idx+1] = promoted.rightPage
+	}
+
+	return t.writeInternal(tx, n)
+}
+
+// Put inserts or overwrites key with value.
+func (t *BTree) Put(key, value []byte) error {
+	tx, err := t.df.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	root := tx.RootPage()
+	if root == 0 {
+		leaf := newLeaf(0)
+		e, err := t.makeEntry(tx, key, value)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		leaf.entries = append(leaf.entries, e)
+
+		newRoot, _, err := t.writeLeaf(tx, leaf)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		tx.SetRootPage(newRoot)
+		return tx.Commit()
+	}
+
+	newRoot, promoted, err := t.insert(tx, root, key, value)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.FreePage(root); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if promoted != nil {
+		parent := newInternal(0)
+		parent.keys = [][]byte{promoted.key}
+		parent.children = []uint32{newRoot, promoted.rightPage}
+
+		newRoot, _, err = t.writeInternal(tx, parent)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	tx.SetRootPage(newRoot)
+	return tx.Commit()
+}
+
+// dropChild removes children[idx] and the separator key on its left
+// (keys[idx-1], or keys[0] if idx was the first child), returning the
+// updated slices.
+func dropChild(children []uint32, keys [][]byte, idx int) ([]uint32, [][]byte) {
+	newChildren := append(append([]uint32(nil), children[:idx]...), children[idx+1:]...)
+
+	keyIdx := idx
+	if keyIdx > 0 {
+		keyIdx--
+	}
+	newKeys := append(append([][]byte(nil), keys[:keyIdx]...), keys[keyIdx+1:]...)
+
+	return newChildren, newKeys
+}
+
+// delete descends from pageNum removing key, copying every node on the
+// path to a new page. It reports whether key was found at all. Nodes that
+// empty out are collapsed into their sibling slot: a leaf that becomes
+// empty is dropped from its parent entirely; an internal node left with
+// only one child is replaced by that child directly. Redistributing keys
+// between siblings (borrowing) is not implemented, so an underfull but
+// nonempty node is left as is.
+func (t *BTree) delete(tx *engine.Tx, pageNum uint32, key []byte) (uint32, bool, error) {
+	n, err := t.readNode(tx, pageNum)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if n.isLeaf() {
+		idx := sort.Search(len(n.entries), func(i int) bool {
+			return bytes.Compare(n.entries[i].key, key) >= 0
+		})
+		if idx >= len(n.entries) || !bytes.Equal(n.entries[idx].key, key) {
+			return 0, false, nil
+		}
+
+		if n.entries[idx].overflow != 0 {
+			if err := t.freeOverflowChain(tx, n.entries[idx].overflow); err != nil {
+				return 0, false, err
+			}
+		}
+		n.entries = append(n.entries[:idx], n.entries[idx+1:]...)
+
+		newPageNum, err := t.persistLeaf(tx, n)
+		return newPageNum, true, err
+	}
+
+	idx := childIndexFor(n, key)
+	childPage := n.children[idx]
+
+	newChildPage, found, err := t.delete(tx, childPage, key)
+	if err != nil || !found {
+		return 0, found, err
+	}
+	if err := tx.FreePage(childPage); err != nil {
+		return 0, false, err
+	}
+
+	child, err := t.readNode(tx, newChildPage)
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch {
+	case child.isLeaf() && len(child.entries) == 0:
+		if err := tx.FreePage(newChildPage); err != nil {
+			return 0, false, err
+		}
+		n.children, n.keys = dropChild(n.children, n.keys, idx)
+	case !child.isLeaf() && len(child.children) == 1:
+		if err := tx.FreePage(newChildPage); err != nil {
+			return 0, false, err
+		}
+		n.children[idx] = child.children[0]
+	default:
+		n.children[idx] = newChildPage
+	}
+
+	newPageNum, err := t.persistInternal(tx, n)
+	return newPageNum, true, err
+}
+
+// Delete removes key, returning ErrKeyNotFound if it wasn't present.
+func (t *BTree) Delete(key []byte) error {
+	tx, err := t.df.Begin(true)
+	if err != nil {
+		return err
+	}
+
+	root := tx.RootPage()
+	if root == 0 {
+		tx.Rollback()
+		return ErrKeyNotFound
+	}
+
+	newRoot, found, err := t.delete(tx, root, key)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !found {
+		tx.Rollback()
+		return ErrKeyNotFound
+	}
+	if err := tx.FreePage(root); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// delete replaces one-child internal nodes below the root; a root
+	// left with one child is replaced by that child here.
+	rootNode, err := t.readNode(tx, newRoot)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !rootNode.isLeaf() && len(rootNode.children) == 1 {
+		if err := tx.FreePage(newRoot); err != nil {
+			tx.Rollback()
+			return err
+		}
+		newRoot = rootNode.children[0]
+	}
+
+	tx.SetRootPage(newRoot)
+	return tx.Commit()
+}
+
+// seek returns the leaf that would hold key, per the usual B+Tree search.
+func (t *BTree) seek(tx *engine.Tx, pageNum uint32, key []byte) (*node, error) {
+	n, err := t.readNode(tx, pageNum)
+	if err != nil {
+		return nil, err
+	}
+	if n.isLeaf() {
+		return n, nil
+	}
+	return t.seek(tx, n.children[childIndexFor(n, key)], key)
+}
+
+// nextLeafAfter returns the leaf immediately following the one containing
+// lastKey, or nil if lastKey's leaf was the last one. It re-descends from
+// root and backtracks to the nearest ancestor with a following sibling,
+// rather than following a leaf's NextPage chain: under copy-on-write,
+// relocating a leaf never rewrites its left sibling, so a sibling's
+// NextPage can reference a page this transaction's snapshot no longer
+// considers current. Re-deriving the successor by key is slower per step
+// but correct regardless of that staleness.
+func (t *BTree) nextLeafAfter(tx *engine.Tx, root uint32, lastKey []byte) (*node, error) {
+	type frame struct {
+		n   *node
+		idx int
+	}
+
+	var path []frame
+	pageNum := root
+	for {
+		n, err := t.readNode(tx, pageNum)
+		if err != nil {
+			return nil, err
+		}
+		if n.isLeaf() {
+			break
+		}
+		idx := childIndexFor(n, lastKey)
+		path = append(path, frame{n: n, idx: idx})
+		pageNum = n.children[idx]
+	}
+
+	for i := len(path) - 1; i >= 0; i-- {
+		f := path[i]
+		if f.idx+1 >= len(f.n.children) {
+			continue
+		}
+
+		pageNum := f.n.children[f.idx+1]
+		for {
+			n, err := t.readNode(tx, pageNum)
+			if err != nil {
+				return nil, err
+			}
+			if n.isLeaf() {
+				return n, nil
+			}
+			pageNum = n.children[0]
+		}
+	}
+
+	return nil, nil
+}
+
+// Range calls fn for every key in [start, end) in ascending order,
+// stopping early if fn returns false. A nil end means no upper bound.
+func (t *BTree) Range(start, end []byte, fn func(key, value []byte) bool) error {
+	tx, err := t.df.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Commit()
+
+	root := tx.RootPage()
+	if root == 0 {
+		return nil
+	}
+
+	leaf, err := t.seek(tx, root, start)
+	if err != nil {
+		return err
+	}
+
+	for leaf != nil {
+		if len(leaf.entries) == 0 {
+			return nil
+		}
+
+		for _, e := range leaf.entries {
+			if bytes.Compare(e.key, start) < 0 {
+				continue
+			}
+			if end != nil && bytes.Compare(e.key, end) >= 0 {
+				return nil
+			}
+
+			value, err := t.entryValue(tx, e)
+			if err != nil {
+				return err
+			}
+			if !fn(e.key, value) {
+				return nil
+			}
+		}
+
+		lastKey := leaf.entries[len(leaf.entries)-1].key
+		leaf, err = t.nextLeafAfter(tx, root, lastKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
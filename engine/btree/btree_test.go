@@ -0,0 +1,390 @@
+package btree
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Rudra-Sankha-Sinhamahapatra/Anon-DB/engine"
+)
+
+func openTree(t *testing.T, path string) *BTree {
+	t.Helper()
+
+	df, err := engine.CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	t.Cleanup(func() { df.Close() })
+
+	tree, err := Open(df)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return tree
+}
+
+func TestPutGetRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, filepath.Join(dir, "btree.db"))
+
+	if err := tree.Put([]byte("alpha"), []byte("1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := tree.Put([]byte("beta"), []byte("2")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := tree.Get([]byte("alpha"))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(got, []byte("1")) {
+		t.Errorf("Get(alpha) = %q, want %q", got, "1")
+	}
+
+	if _, err := tree.Get([]byte("missing")); err != ErrKeyNotFound {
+		t.Errorf("Get(missing) err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestPutOverwritesExistingKey(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, filepath.Join(dir, "btree.db"))
+
+	if err := tree.Put([]byte("k"), []byte("v1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := tree.Put([]byte("k"), []byte("v2")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := tree.Get([]byte("k"))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(got, []byte("v2")) {
+		t.Errorf("Get(k) = %q, want %q", got, "v2")
+	}
+}
+
+// TestLeafSplitsAcrossManyInserts inserts enough keys that leaves (and
+// then internal nodes) must split, and verifies every key is still
+// reachable afterward.
+func TestLeafSplitsAcrossManyInserts(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, filepath.Join(dir, "btree.db"))
+
+	const n = 500
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key-%04d", i))
+		value := []byte(fmt.Sprintf("value-%04d", i))
+		if err := tree.Put(key, value); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("key-%04d", i))
+		want := []byte(fmt.Sprintf("value-%04d", i))
+		got, err := tree.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", key, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Get(%s) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestOversizeValueSpillsToOverflow(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, filepath.Join(dir, "btree.db"))
+
+	big := bytes.Repeat([]byte("x"), inlineValueThreshold*3+17)
+	if err := tree.Put([]byte("blob"), big); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := tree.Get([]byte("blob"))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(got, big) {
+		t.Errorf("Get(blob) returned %d bytes, want %d", len(got), len(big))
+	}
+}
+
+func TestDeleteRemovesKeyAndMergesEmptyLeaf(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, filepath.Join(dir, "btree.db"))
+
+	const n = 300
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("k-%04d", i))
+		if err := tree.Put(key, key); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	for i := 0; i < n; i += 2 {
+		key := []byte(fmt.Sprintf("k-%04d", i))
+		if err := tree.Delete(key); err != nil {
+			t.Fatalf("Delete(%s): %v", key, err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("k-%04d", i))
+		_, err := tree.Get(key)
+		if i%2 == 0 {
+			if err != ErrKeyNotFound {
+				t.Errorf("Get(%s) err = %v, want ErrKeyNotFound", key, err)
+			}
+		} else if err != nil {
+			t.Errorf("Get(%s): %v", key, err)
+		}
+	}
+
+	if err := tree.Delete([]byte("k-0000")); err != ErrKeyNotFound {
+		t.Errorf("Delete(already-deleted) err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestRangeScansInOrderAcrossLeafSplits(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, filepath.Join(dir, "btree.db"))
+
+	const n = 400
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("r-%04d", i))
+		if err := tree.Put(key, key); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	var seen []string
+	err := tree.Range([]byte("r-0100"), []byte("r-0110"), func(key, value []byte) bool {
+		seen = append(seen, string(key))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+
+	if len(seen) != 10 {
+		t.Fatalf("Range returned %d keys, want 10: %v", len(seen), seen)
+	}
+	for i, key := range seen {
+		want := fmt.Sprintf("r-%04d", 100+i)
+		if key != want {
+			t.Errorf("seen[%d] = %q, want %q", i, key, want)
+		}
+	}
+}
+
+func TestRangeStopsEarlyWhenFnReturnsFalse(t *testing.T) {
+	dir := t.TempDir()
+	tree := openTree(t, filepath.Join(dir, "btree.db"))
+
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("s-%04d", i))
+		if err := tree.Put(key, key); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	count := 0
+	err := tree.Range([]byte("s-0000"), nil, func(key, value []byte) bool {
+		count++
+		return count < 5
+	})
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("Range invoked fn %d times, want 5", count)
+	}
+}
+
+// TestReopenAfterCrash simulates a crash by closing the DBFile without an
+// explicit WAL checkpoint and reopening it fresh, checking that every key
+// written beforehand survives WAL replay.
+func TestReopenAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "btree.db")
+
+	df, err := engine.CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	tree, err := Open(df)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("c-%04d", i))
+		if err := tree.Put(key, key); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	if err := df.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := engine.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer reopened.Close()
+
+	recovered, err := Open(reopened)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("c-%04d", i))
+		got, err := recovered.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%s) after reopen: %v", key, err)
+		}
+		if !bytes.Equal(got, key) {
+			t.Errorf("Get(%s) = %q, want %q", key, got, key)
+		}
+	}
+}
+
+// TestPutDeleteChurnDoesNotGrowFileUnbounded guards against
+// Tx.AllocatePage ignoring the on-disk free list: before the fix, every
+// round of Put-then-Delete over the same keys grew the backing file by a
+// full round's worth of pages, because nothing ever consulted FirstFree
+// even though mergeReclaimableFreePages had already chained the freed
+// pages into it. File size should stop growing once steady state is
+// reached, instead of climbing every round.
+func TestPutDeleteChurnDoesNotGrowFileUnbounded(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "churn.db")
+
+	df, err := engine.CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	tree, err := Open(df)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	const keys = 50
+	const rounds = 5
+
+	churn := func() {
+		for i := 0; i < keys; i++ {
+			key := []byte(fmt.Sprintf("churn-%04d", i))
+			if err := tree.Put(key, key); err != nil {
+				t.Fatalf("Put(%s): %v", key, err)
+			}
+		}
+		for i := 0; i < keys; i++ {
+			key := []byte(fmt.Sprintf("churn-%04d", i))
+			if err := tree.Delete(key); err != nil {
+				t.Fatalf("Delete(%s): %v", key, err)
+			}
+		}
+	}
+
+	fileSize := func() int64 {
+		if err := df.Sync(); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		return info.Size()
+	}
+
+	// The first couple of rounds may still grow the file: the very first
+	// round has nothing on the free list yet, and a page freed by round 1
+	// only becomes reclaimable once no snapshot predates its Generation.
+	churn()
+	churn()
+	steadySize := fileSize()
+
+	for round := 0; round < rounds; round++ {
+		churn()
+		if size := fileSize(); size > steadySize {
+			t.Errorf("round %d: file grew from %d to %d bytes, want it to stay at or below steady state", round, steadySize, size)
+		}
+	}
+}
+
+func TestBulkLoadBuildsSearchableTree(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bulk.db")
+
+	df, err := engine.CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	const n = 600
+	input := make([]KV, n)
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("b-%05d", i))
+		input[i] = KV{Key: key, Value: append([]byte(nil), key...)}
+	}
+
+	tree, err := BulkLoad(df, input)
+	if err != nil {
+		t.Fatalf("BulkLoad: %v", err)
+	}
+
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("b-%05d", i))
+		got, err := tree.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", key, err)
+		}
+		if !bytes.Equal(got, key) {
+			t.Errorf("Get(%s) = %q, want %q", key, got, key)
+		}
+	}
+
+	var seen int
+	if err := tree.Range([]byte("b-00000"), nil, func(key, value []byte) bool {
+		seen++
+		return true
+	}); err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if seen != n {
+		t.Errorf("Range visited %d keys, want %d", seen, n)
+	}
+}
+
+func TestBulkLoadEmptyInput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "empty.db")
+
+	df, err := engine.CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	tree, err := BulkLoad(df, nil)
+	if err != nil {
+		t.Fatalf("BulkLoad: %v", err)
+	}
+	if _, err := tree.Get([]byte("anything")); err != ErrKeyNotFound {
+		t.Errorf("Get on empty bulk-loaded tree err = %v, want ErrKeyNotFound", err)
+	}
+}
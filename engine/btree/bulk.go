@@ -0,0 +1,148 @@
+package btree
+
+import (
+	"github.com/Rudra-Sankha-Sinhamahapatra/Anon-DB/engine"
+)
+
+// KV is one key/value pair fed to BulkLoad.
+type KV struct {
+	Key, Value []byte
+}
+
+// BulkLoad builds a new B+Tree bottom-up from sorted, deduplicated input
+// and installs it as df's root, replacing whatever tree (if any) was
+// already there. This is meant for import scenarios where the caller
+// already has the full key set in hand: packing leaves greedily in one
+// pass produces a denser, more balanced tree than the same input fed
+// through repeated Put calls, and avoids the page churn of splitting as
+// it goes. The input must already be sorted by Key and must not repeat a
+// key; BulkLoad does not check either.
+func BulkLoad(df *engine.DBFile, input []KV) (*BTree, error) {
+	t := &BTree{df: df}
+
+	tx, err := df.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input) == 0 {
+		tx.SetRootPage(0)
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	pages, firstKeys, err := t.bulkLoadLeaves(tx, input)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	root, err := t.bulkLoadLevel(tx, pages, firstKeys)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.SetRootPage(root)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// bulkLoadLeaves packs input into as few leaf nodes as fit within
+// nodeCapacity and persists them. Leaves are written back to front so
+// each can be given a correct nextLeaf pointer to its already-persisted
+// right sibling; unlike during Put/Delete, that pointer is trustworthy
+// here since the whole tree is built in one pass with no later
+// copy-on-write relocation to go stale against. It returns each leaf's
+// page number and its first key, for the caller to build parent
+// separators from.
+func (t *BTree) bulkLoadLeaves(tx *engine.Tx, input []KV) ([]uint32, [][]byte, error) {
+	var leaves []*node
+	cur := newLeaf(0)
+
+	for _, kv := range input {
+		e, err := t.makeEntry(tx, kv.Key, kv.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		trial := &node{kind: leafKind, entries: append(append([]entry(nil), cur.entries...), e)}
+		if trial.encodedSize() > nodeCapacity && len(cur.entries) > 0 {
+			leaves = append(leaves, cur)
+			cur = newLeaf(0)
+		}
+		cur.entries = append(cur.entries, e)
+	}
+	leaves = append(leaves, cur)
+
+	pages := make([]uint32, len(leaves))
+	firstKeys := make([][]byte, len(leaves))
+
+	var nextPage uint32
+	for i := len(leaves) - 1; i >= 0; i-- {
+		leaves[i].nextLeaf = nextPage
+		pageNum, err := t.persistLeaf(tx, leaves[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		pages[i] = pageNum
+		firstKeys[i] = leaves[i].entries[0].key
+		nextPage = pageNum
+	}
+
+	return pages, firstKeys, nil
+}
+
+// bulkLoadLevel packs (child, firstKey) pairs into internal nodes one
+// level at a time, recursing on the resulting level's own (page,
+// firstKey) pairs until exactly one page remains: the root.
+func (t *BTree) bulkLoadLevel(tx *engine.Tx, pages []uint32, firstKeys [][]byte) (uint32, error) {
+	if len(pages) == 1 {
+		return pages[0], nil
+	}
+
+	var levelPages []uint32
+	var levelFirstKeys [][]byte
+
+	cur := newInternal(0)
+	cur.children = []uint32{pages[0]}
+	curFirstKey := firstKeys[0]
+
+	flush := func() error {
+		pageNum, err := t.persistInternal(tx, cur)
+		if err != nil {
+			return err
+		}
+		levelPages = append(levelPages, pageNum)
+		levelFirstKeys = append(levelFirstKeys, curFirstKey)
+		return nil
+	}
+
+	for i := 1; i < len(pages); i++ {
+		trial := &node{
+			kind:     internalKind,
+			keys:     append(append([][]byte(nil), cur.keys...), firstKeys[i]),
+			children: append(append([]uint32(nil), cur.children...), pages[i]),
+		}
+		if trial.encodedSize() > nodeCapacity && len(cur.children) > 1 {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+			cur = newInternal(0)
+			cur.children = []uint32{pages[i]}
+			curFirstKey = firstKeys[i]
+			continue
+		}
+		cur.keys = append(cur.keys, firstKeys[i])
+		cur.children = append(cur.children, pages[i])
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+
+	return t.bulkLoadLevel(tx, levelPages, levelFirstKeys)
+}
@@ -0,0 +1,202 @@
+package btree
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/Rudra-Sankha-Sinhamahapatra/Anon-DB/engine"
+)
+
+// nodeCapacity is how many bytes of encoded node an index page can hold.
+// It leaves headroom below engine.PageSize-engine.PageHeaderSize for the
+// length prefix and any codec overhead Page.Serialize adds on top of the
+// node's own encoding.
+const nodeCapacity = engine.PageSize - engine.PageHeaderSize - 64
+
+// inlineValueThreshold is the largest value a leaf entry stores inline.
+// Anything bigger spills into a PageTypeOverflow chain and the entry keeps
+// only the chain's head page number.
+const inlineValueThreshold = 512
+
+// ErrNodeTooLarge is returned by encodeNode when a node's entries no
+// longer fit in one page and must be split before being written.
+var ErrNodeTooLarge = errors.New("btree: node too large for one page")
+
+type nodeKind byte
+
+const (
+	leafKind nodeKind = iota
+	internalKind
+)
+
+// entry is one key/value slot in a leaf node.
+type entry struct {
+	key      []byte
+	value    []byte // inline value; empty when overflow != 0
+	overflow uint32 // head page of this value's overflow chain; 0 when inline
+	valueLen uint32 // logical value length, needed to size the read-back buffer when overflow != 0
+}
+
+// node is the decoded, in-memory form of one B+Tree page. Internal nodes
+// hold len(keys) separator keys and len(keys)+1 children; keys[i] is the
+// smallest key reachable through children[i+1]. Leaf nodes hold sorted
+// entries and record the next leaf via nextLeaf as of when they were
+// written; Range does not follow that pointer, since copy-on-write can
+// leave it stale (see nextLeafAfter).
+type node struct {
+	kind     nodeKind
+	pageNum  uint32
+	nextLeaf uint32 // leaf-only: the next leaf in key order when this leaf was written, 0 if it was the last
+
+	keys     [][]byte
+	children []uint32
+
+	entries []entry
+}
+
+func newLeaf(pageNum uint32) *node {
+	return &node{kind: leafKind, pageNum: pageNum}
+}
+
+func newInternal(pageNum uint32) *node {
+	return &node{kind: internalKind, pageNum: pageNum}
+}
+
+func (n *node) isLeaf() bool { return n.kind == leafKind }
+
+// encodedSize returns the byte length encodeNode would produce for n,
+// without allocating it — used to decide whether a node needs to split
+// before it is written, and by BulkLoad whether one more entry still fits.
+func (n *node) encodedSize() int {
+	size := 3 // kind + uint16 count
+	if n.kind == leafKind {
+		for _, e := range n.entries {
+			size += 2 + len(e.key) + 1 + 4
+			if e.overflow == 0 {
+				size += len(e.value)
+			} else {
+				size += 4
+			}
+		}
+	} else {
+		size += 4 // children[0]
+		for _, k := range n.keys {
+			size += 2 + len(k) + 4
+		}
+	}
+	return size
+}
+
+// encodeNode serializes n into a buffer suitable for Page.WriteData(0, ...).
+func encodeNode(n *node) ([]byte, error) {
+	size := n.encodedSize()
+	if size > nodeCapacity {
+		return nil, ErrNodeTooLarge
+	}
+
+	buf := make([]byte, size)
+	pos := 0
+
+	buf[pos] = byte(n.kind)
+	pos++
+
+	if n.kind == leafKind {
+		binary.LittleEndian.PutUint16(buf[pos:], uint16(len(n.entries)))
+		pos += 2
+
+		for _, e := range n.entries {
+			binary.LittleEndian.PutUint16(buf[pos:], uint16(len(e.key)))
+			pos += 2
+			copy(buf[pos:], e.key)
+			pos += len(e.key)
+
+			if e.overflow == 0 {
+				buf[pos] = 0
+				pos++
+				binary.LittleEndian.PutUint32(buf[pos:], uint32(len(e.value)))
+				pos += 4
+				copy(buf[pos:], e.value)
+				pos += len(e.value)
+			} else {
+				buf[pos] = 1
+				pos++
+				binary.LittleEndian.PutUint32(buf[pos:], e.valueLen)
+				pos += 4
+				binary.LittleEndian.PutUint32(buf[pos:], e.overflow)
+				pos += 4
+			}
+		}
+		return buf, nil
+	}
+
+	binary.LittleEndian.PutUint16(buf[pos:], uint16(len(n.keys)))
+	pos += 2
+	binary.LittleEndian.PutUint32(buf[pos:], n.children[0])
+	pos += 4
+	for i, k := range n.keys {
+		binary.LittleEndian.PutUint16(buf[pos:], uint16(len(k)))
+		pos += 2
+		copy(buf[pos:], k)
+		pos += len(k)
+		binary.LittleEndian.PutUint32(buf[pos:], n.children[i+1])
+		pos += 4
+	}
+	return buf, nil
+}
+
+// decodeNode parses a buffer produced by encodeNode. pageNum and nextLeaf
+// come from the Page this node was read off of (PageNum and NextPage
+// respectively), since neither is part of the node's own encoding.
+func decodeNode(buf []byte, pageNum, nextLeaf uint32) (*node, error) {
+	if len(buf) < 3 {
+		return nil, errors.New("btree: truncated node")
+	}
+
+	kind := nodeKind(buf[0])
+	count := int(binary.LittleEndian.Uint16(buf[1:3]))
+	pos := 3
+
+	if kind == leafKind {
+		n := newLeaf(pageNum)
+		n.nextLeaf = nextLeaf
+		for i := 0; i < count; i++ {
+			keyLen := int(binary.LittleEndian.Uint16(buf[pos:]))
+			pos += 2
+			key := append([]byte(nil), buf[pos:pos+keyLen]...)
+			pos += keyLen
+
+			isOverflow := buf[pos]
+			pos++
+			valueLen := binary.LittleEndian.Uint32(buf[pos:])
+			pos += 4
+
+			e := entry{key: key, valueLen: valueLen}
+			if isOverflow == 0 {
+				e.value = append([]byte(nil), buf[pos:pos+int(valueLen)]...)
+				pos += int(valueLen)
+			} else {
+				e.overflow = binary.LittleEndian.Uint32(buf[pos:])
+				pos += 4
+			}
+			n.entries = append(n.entries, e)
+		}
+		return n, nil
+	}
+
+	n := newInternal(pageNum)
+	child0 := binary.LittleEndian.Uint32(buf[pos:])
+	pos += 4
+	n.children = append(n.children, child0)
+	for i := 0; i < count; i++ {
+		keyLen := int(binary.LittleEndian.Uint16(buf[pos:]))
+		pos += 2
+		key := append([]byte(nil), buf[pos:pos+keyLen]...)
+		pos += keyLen
+		child := binary.LittleEndian.Uint32(buf[pos:])
+		pos += 4
+
+		n.keys = append(n.keys, key)
+		n.children = append(n.children, child)
+	}
+	return n, nil
+}
@@ -0,0 +1,77 @@
+package btree
+
+import (
+	"encoding/binary"
+
+	"github.com/Rudra-Sankha-Sinhamahapatra/Anon-DB/engine"
+)
+
+// overflowChunkSize is how many value bytes each PageTypeOverflow page
+// holds, leaving room for the chunk's own length prefix and for the 4-byte
+// length prefix Page.Serialize writes ahead of the payload.
+const overflowChunkSize = engine.PageSize - engine.PageHeaderSize - 4 - 4
+
+// writeOverflow splits value across as many PageTypeOverflow pages as it
+// takes, chained via Page.SetNextPage, and returns the head page number.
+func writeOverflow(tx *engine.Tx, value []byte) (uint32, error) {
+	var head uint32
+	var prev *engine.Page
+
+	for offset := 0; offset < len(value); offset += overflowChunkSize {
+		end := offset + overflowChunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		chunk := value[offset:end]
+
+		page, err := tx.AllocatePage(engine.PageTypeOverflow)
+		if err != nil {
+			return 0, err
+		}
+
+		buf := make([]byte, 4+len(chunk))
+		binary.LittleEndian.PutUint32(buf, uint32(len(chunk)))
+		copy(buf[4:], chunk)
+		if err := page.WriteData(0, buf); err != nil {
+			return 0, err
+		}
+
+		if prev != nil {
+			prev.SetNextPage(page.GetPageNum())
+		} else {
+			head = page.GetPageNum()
+		}
+		prev = page
+	}
+
+	return head, nil
+}
+
+// readOverflow reassembles the value chained from head, stopping once
+// length bytes have been read back.
+func readOverflow(tx *engine.Tx, head uint32, length uint32) ([]byte, error) {
+	value := make([]byte, 0, length)
+
+	for pageNum := head; pageNum != 0 && uint32(len(value)) < length; {
+		page, err := tx.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+
+		header, err := page.ReadData(0, 4)
+		if err != nil {
+			return nil, err
+		}
+		chunkLen := binary.LittleEndian.Uint32(header)
+
+		chunk, err := page.ReadData(4, uint16(chunkLen))
+		if err != nil {
+			return nil, err
+		}
+
+		value = append(value, chunk...)
+		pageNum = page.NextPage()
+	}
+
+	return value, nil
+}
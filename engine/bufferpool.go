@@ -0,0 +1,282 @@
+package engine
+
+import "sync"
+
+// bufferPoolShardCount is how many independently-locked shards a
+// BufferPool splits its capacity across, the same way segmentManager
+// splits pages across files: spreading pageNum hashes across shards keeps
+// concurrent transactions from serializing on one lock.
+const bufferPoolShardCount = 16
+
+// PoolStats reports a BufferPool's activity since it was created.
+type PoolStats struct {
+	Hits      uint64 // ReadPage calls served from the pool
+	Misses    uint64 // ReadPage calls that had to read through to disk
+	Evictions uint64 // Frames dropped to make room for a new page
+	Dirty     uint64 // Frames currently holding a write not yet flushed to disk
+}
+
+// frame is one cached page slot. pinned is a count rather than a bool
+// because a frame can be pinned more than once (each put with pinned=true,
+// or pin, adds one) and each pin is released by its own unpin; a cache hit
+// does not pin. A frame is only eligible for eviction once it drops back
+// to zero.
+type frame struct {
+	page     *Page
+	dirty    bool
+	pinned   int
+	accessed bool // CLOCK's second-chance bit; set on insert/hit, cleared by a sweep pass
+}
+
+// bufferShard is one partition of a BufferPool, guarding its own frames
+// map and CLOCK hand with a single mutex.
+type bufferShard struct {
+	mu       sync.Mutex
+	capacity int
+	frames   map[uint32]*frame
+	clock    []uint32 // pageNums in insertion order; order the CLOCK hand sweeps
+	hand     int
+}
+
+// BufferPool caches deserialized *Page values in front of a DBFile's
+// readPage/writePage, keyed by page number, so repeated access to the same
+// page skips the disk (and, once a dirty page is written back, the extra
+// random write a straight-through writePage would have done immediately).
+// Replacement uses CLOCK (second-chance): a full shard sweeps its frames
+// looking for one that is both unpinned and has not been touched since the
+// last sweep, writing it back first if it is dirty.
+//
+// A BufferPool's capacity is soft: AllocatePage pins a freshly inserted
+// page until the caller releases it via DBFile.UnpinPage, and a pinned
+// frame is never evicted, so a workload that never unpins can grow a shard
+// past its configured capacity. This mirrors the tradeoff every pinning
+// cache makes — correctness over a hard memory bound.
+type BufferPool struct {
+	shards [bufferPoolShardCount]*bufferShard
+
+	mu    sync.Mutex // Guards the stats counters below
+	stats PoolStats
+}
+
+// NewBufferPool creates a BufferPool with room for roughly capacity pages,
+// split evenly across its shards.
+func NewBufferPool(capacity int) *BufferPool {
+	perShard := capacity / bufferPoolShardCount
+	if perShard < 1 {
+		perShard = 1
+	}
+
+	bp := &BufferPool{}
+	for i := range bp.shards {
+		bp.shards[i] = &bufferShard{
+			capacity: perShard,
+			frames:   make(map[uint32]*frame),
+		}
+	}
+	return bp
+}
+
+func (bp *BufferPool) shardFor(pageNum uint32) *bufferShard {
+	return bp.shards[pageNum%bufferPoolShardCount]
+}
+
+// get returns the cached page for pageNum and records a hit, or records a
+// miss and returns (nil, false). A hit sets the CLOCK second-chance bit
+// but does not pin the frame: ordinary reads are expected to be done with
+// the page by the time the caller's stack frame returns, unlike the
+// allocate-then-populate window AllocatePage protects with a real pin.
+func (bp *BufferPool) get(pageNum uint32) (*Page, bool) {
+	shard := bp.shardFor(pageNum)
+
+	shard.mu.Lock()
+	f, ok := shard.frames[pageNum]
+	if ok {
+		f.accessed = true
+	}
+	shard.mu.Unlock()
+
+	bp.mu.Lock()
+	if ok {
+		bp.stats.Hits++
+	} else {
+		bp.stats.Misses++
+	}
+	bp.mu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+	return f.page, true
+}
+
+// put inserts or refreshes the frame for pageNum. dirty marks the page as
+// not yet flushed to its segment; pinned protects a freshly allocated page
+// from the eviction sweep until UnpinPage releases it. If inserting grows
+// this pageNum's shard past capacity, put evicts one unpinned frame first,
+// calling writeBack on it if it was dirty.
+func (bp *BufferPool) put(pageNum uint32, page *Page, dirty, pinned bool, writeBack func(*Page) error) error {
+	shard := bp.shardFor(pageNum)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	f, exists := shard.frames[pageNum]
+	if !exists {
+		f = &frame{}
+		shard.frames[pageNum] = f
+		shard.clock = append(shard.clock, pageNum)
+	}
+	f.page = page
+	f.accessed = true
+	if dirty {
+		f.dirty = true
+	}
+	if pinned {
+		f.pinned++
+	}
+
+	if len(shard.frames) <= shard.capacity {
+		return nil
+	}
+
+	evicted, err := shard.evictLocked(writeBack)
+	if err != nil {
+		return err
+	}
+	if evicted {
+		bp.mu.Lock()
+		bp.stats.Evictions++
+		bp.mu.Unlock()
+	}
+	return nil
+}
+
+// evictLocked runs one CLOCK sweep looking for a frame to drop: a pinned
+// frame is skipped outright, an accessed-but-unpinned frame gets its bit
+// cleared and a second chance, and the first frame found with neither is
+// the victim. Returns false, having evicted nothing, if every live frame
+// is pinned. Callers must hold shard.mu.
+func (shard *bufferShard) evictLocked(writeBack func(*Page) error) (bool, error) {
+	maxSteps := 2*len(shard.clock) + 1
+	for step := 0; step < maxSteps; step++ {
+		if len(shard.clock) == 0 {
+			return false, nil
+		}
+		if shard.hand >= len(shard.clock) {
+			shard.hand = 0
+		}
+
+		pageNum := shard.clock[shard.hand]
+		f, ok := shard.frames[pageNum]
+		if !ok {
+			// Already removed (e.g. by a prior sweep); drop the stale
+			// clock entry and keep looking at the same index.
+			shard.clock = append(shard.clock[:shard.hand], shard.clock[shard.hand+1:]...)
+			continue
+		}
+
+		if f.pinned > 0 {
+			shard.hand++
+			continue
+		}
+		if f.accessed {
+			f.accessed = false
+			shard.hand++
+			continue
+		}
+
+		if f.dirty {
+			if err := writeBack(f.page); err != nil {
+				return false, err
+			}
+		}
+		delete(shard.frames, pageNum)
+		shard.clock = append(shard.clock[:shard.hand], shard.clock[shard.hand+1:]...)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// pin increments pageNum's frame's pin count, protecting it from eviction.
+// A no-op if the frame doesn't exist (it was either never cached or has
+// already been evicted).
+func (bp *BufferPool) pin(pageNum uint32) {
+	shard := bp.shardFor(pageNum)
+	shard.mu.Lock()
+	if f, ok := shard.frames[pageNum]; ok {
+		f.pinned++
+	}
+	shard.mu.Unlock()
+}
+
+// unpin releases one pin placed on pageNum's frame, letting a future
+// eviction sweep consider it once the count reaches zero.
+func (bp *BufferPool) unpin(pageNum uint32) {
+	shard := bp.shardFor(pageNum)
+	shard.mu.Lock()
+	if f, ok := shard.frames[pageNum]; ok && f.pinned > 0 {
+		f.pinned--
+	}
+	shard.mu.Unlock()
+}
+
+// flush writes back every dirty frame across every shard, clearing their
+// dirty flag. Called from DBFile.Flush and from Checkpoint, since a
+// checkpoint's fsync is only meaningful once the pool's deferred writes
+// have actually reached the segment files.
+func (bp *BufferPool) flush(writeBack func(*Page) error) error {
+	for _, shard := range bp.shards {
+		shard.mu.Lock()
+		err := func() error {
+			for _, f := range shard.frames {
+				if !f.dirty {
+					continue
+				}
+				if err := writeBack(f.page); err != nil {
+					return err
+				}
+				f.dirty = false
+			}
+			return nil
+		}()
+		shard.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns a snapshot of this pool's hit/miss/eviction counters plus
+// the number of frames currently dirty (not cumulative, unlike the other
+// three: it reflects frames right now, since a dirty frame can be flushed
+// and later redirtied any number of times).
+func (bp *BufferPool) Stats() PoolStats {
+	bp.mu.Lock()
+	stats := bp.stats
+	bp.mu.Unlock()
+
+	var dirty uint64
+	for _, shard := range bp.shards {
+		shard.mu.Lock()
+		for _, f := range shard.frames {
+			if f.dirty {
+				dirty++
+			}
+		}
+		shard.mu.Unlock()
+	}
+	stats.Dirty = dirty
+
+	return stats
+}
+
+// WithBufferPool configures a DBFile to cache pages in a BufferPool of the
+// given capacity (pages per shard is capacity/16, minimum 1) instead of
+// reading and writing straight through to disk on every call.
+func WithBufferPool(capacity int) DBFileOption {
+	return func(df *DBFile) {
+		df.pool = NewBufferPool(capacity)
+	}
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/golang/snappy"
+)
+
+// Codec IDs recorded in PageHeader.CodecID. CodecIdentity is reserved for
+// uncompressed pages (including every page written before this codec
+// registry existed, since the field's zero value decodes to it) and must
+// never be reassigned.
+const (
+	CodecIdentity byte = iota
+	CodecGzip
+	CodecSnappy
+)
+
+// ErrUnknownCodec is returned when a page's CodecID has no registered
+// Compressor, e.g. the file was written by a build with a codec this one
+// doesn't have compiled in.
+var ErrUnknownCodec = errors.New("engine: unknown page codec")
+
+// Compressor compresses and decompresses page payloads. Implementations
+// are registered under a fixed ID so a page serialized with one codec can
+// always be identified and decompressed later, regardless of which codec
+// (if any) the DBFile that reopens it is configured with.
+type Compressor interface {
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var codecs = map[byte]Compressor{}
+
+// registerCodec adds c to the codec registry. Called from init for the
+// built-in codecs; a duplicate ID can only come from a programming error.
+func registerCodec(c Compressor) {
+	if _, exists := codecs[c.ID()]; exists {
+		panic("engine: duplicate codec ID registered")
+	}
+	codecs[c.ID()] = c
+}
+
+func init() {
+	registerCodec(gzipCompressor{})
+	registerCodec(snappyCompressor{})
+}
+
+// lookupCodec returns the compressor for id. CodecIdentity always
+// resolves without a registry lookup so identity never depends on init
+// order or can be shadowed by a bad registration.
+func lookupCodec(id byte) (Compressor, error) {
+	if id == CodecIdentity {
+		return identityCompressor{}, nil
+	}
+
+	c, ok := codecs[id]
+	if !ok {
+		return nil, ErrUnknownCodec
+	}
+	return c, nil
+}
+
+type identityCompressor struct{}
+
+func (identityCompressor) ID() byte { return CodecIdentity }
+
+func (identityCompressor) Compress(d []byte) ([]byte, error) { return d, nil }
+
+func (identityCompressor) Decompress(d []byte) ([]byte, error) { return d, nil }
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) ID() byte { return CodecGzip }
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) ID() byte { return CodecSnappy }
+
+func (snappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCompressor) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+// DBFileOption configures a DBFile at create/open time.
+type DBFileOption func(*DBFile)
+
+// WithCompression configures c as the codec applied to every page this
+// DBFile writes from now on. Pages already on disk keep decompressing
+// with whichever codec they were written under, since that ID travels
+// with the page, not the DBFile.
+func WithCompression(c Compressor) DBFileOption {
+	return func(df *DBFile) {
+		df.compressor = c
+	}
+}
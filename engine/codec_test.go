@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPageSerializeRoundTripsPerCodec(t *testing.T) {
+	payload := bytes.Repeat([]byte("anon-db"), 200)
+
+	codecs := []struct {
+		name string
+		c    Compressor
+	}{
+		{"identity", nil},
+		{"gzip", gzipCompressor{}},
+		{"snappy", snappyCompressor{}},
+	}
+
+	for _, tc := range codecs {
+		t.Run(tc.name, func(t *testing.T) {
+			page := NewPage(3, PageTypeData)
+			if err := page.WriteData(0, payload); err != nil {
+				t.Fatalf("WriteData: %v", err)
+			}
+
+			buf, err := page.Serialize(tc.c)
+			if err != nil {
+				t.Fatalf("Serialize: %v", err)
+			}
+
+			got := &Page{}
+			if err := got.Deserialize(buf); err != nil {
+				t.Fatalf("Deserialize: %v", err)
+			}
+
+			if !bytes.Equal(got.data[:len(payload)], payload) {
+				t.Errorf("round-tripped payload mismatch for codec %s", tc.name)
+			}
+		})
+	}
+}
+
+func TestDeserializeUnknownCodecID(t *testing.T) {
+	page := NewPage(1, PageTypeData)
+	buf, err := page.Serialize(nil)
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	buf[21] = 0xFF // no codec is ever registered at this ID
+
+	if err := (&Page{}).Deserialize(buf); err != ErrUnknownCodec {
+		t.Errorf("expected ErrUnknownCodec, got %v", err)
+	}
+}
+
+func benchmarkPageSerialize(b *testing.B, c Compressor) {
+	page := NewPage(0, PageTypeData)
+	if err := page.WriteData(0, bytes.Repeat([]byte("anon-db-bench"), 150)); err != nil {
+		b.Fatalf("WriteData: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := page.Serialize(c); err != nil {
+			b.Fatalf("Serialize: %v", err)
+		}
+	}
+}
+
+func BenchmarkPageSerializeRaw(b *testing.B) {
+	benchmarkPageSerialize(b, nil)
+}
+
+func BenchmarkPageSerializeGzip(b *testing.B) {
+	benchmarkPageSerialize(b, gzipCompressor{})
+}
+
+func BenchmarkPageSerializeSnappy(b *testing.B) {
+	benchmarkPageSerialize(b, snappyCompressor{})
+}
@@ -15,8 +15,28 @@ const (
 	// FileHeaderSize is the size of the database file header in bytes
 	FileHeaderSize = 32
 
+	// headerSlotCount is the number of alternating header slots kept at
+	// the front of the file so a header write can never tear a readable
+	// copy: the slot used for a write is derived from Generation, so
+	// commits alternate between offset 0 and offset FileHeaderSize.
+	headerSlotCount = 2
+
+	// dataOffset is where page data begins, after both header slots.
+	dataOffset = headerSlotCount * FileHeaderSize
+
 	// Initial size allocated for the free page list
 	InitialFreeListSize = 8
+
+	// FileVersionV1 predates per-page compression: every page in a v1
+	// file was written with an implicit CodecIdentity and no length
+	// prefix in its data region.
+	FileVersionV1 = 1
+
+	// FileVersionCurrent is written by CreateFile. Pages may carry a
+	// CodecID and UncompressedLen; CodecID 0 still means uncompressed,
+	// so nothing configured via WithCompression changes how a v1 file's
+	// own pages decode.
+	FileVersionCurrent = 2
 )
 
 // Common errors that can occur during file operations
@@ -28,12 +48,13 @@ var (
 
 // FileHeader represents the metadata stored at the beginning of each database file
 type FileHeader struct {
-	MagicNumber uint32   // Identifies this as our database file
-	Version     uint32   // Database file version
-	PageCount   uint32   // Total number of pages in the file
-	FirstFree   uint32   // First free page number (for reuse)
-	RootPage    uint32   // Root page number (usually for B-tree)
-	Reserved    [12]byte // Reserved for future use
+	MagicNumber   uint32 // Identifies this as our database file
+	Version       uint32 // Database file version
+	PageCount     uint32 // Total number of pages in the file
+	FirstFree     uint32 // First free page number (for reuse)
+	RootPage      uint32 // Root page number (usually for B-tree)
+	CheckpointLSN uint64 // LSN of the last WAL record applied to this file
+	Generation    uint32 // Bumped on every header write; selects the active slot and orders snapshots
 }
 
 // DBFile represents a database file on disk
@@ -42,10 +63,31 @@ type DBFile struct {
 	header   FileHeader   // File header containing metadata
 	mutex    sync.RWMutex // Mutex for thread-safe operations
 	filepath string       // Path to the database file
+	wal      *WAL         // Write-ahead log guarding durability of page writes
+	readOnly bool         // True when the file was opened via OpenFileReadOnly
+
+	compressor Compressor // Codec applied to pages this DBFile writes; nil means CodecIdentity
+
+	writeMu sync.Mutex // Serializes writable transactions (single-writer)
+
+	snapMu        sync.Mutex          // Guards openSnapshots, pendingFree and retiredMmaps below
+	openSnapshots map[uint32]int      // Refcount of Tx snapshots still reading a given Generation
+	pendingFree   map[uint32][]uint32 // Pages freed by a commit at Generation, not yet safe to reuse
+	retiredMmaps  map[uint32][][]byte // Mappings superseded by a remap at Generation, not yet safe to munmap
+
+	mmap        []byte // Read-only mapping covering segment 0 up to mmapLen; nil unless opened via OpenFileMmap
+	mmapLen     int    // Bytes currently mapped, always a multiple of os.Getpagesize()
+	mmapEnabled bool   // True once enableMmapLocked has succeeded; readPage consults this under df.mutex
+
+	maxSegmentSize int64 // Set via WithMaxSegmentSize; 0 before opts run means "use DefaultMaxSegmentSize"
+	singleSegment  bool  // Set via WithSingleSegmentFile; keeps every page in segment 0 regardless of size
+	segMgr         *segmentManager
+
+	pool *BufferPool // Set via WithBufferPool; nil means readPage/writePage go straight through to disk
 }
 
 // CreateFile creates a new database file at the specified path
-func CreateFile(filepath string) (*DBFile, error) {
+func CreateFile(filepath string, opts ...DBFileOption) (*DBFile, error) {
 	// Create new file with read/write permissions
 	file, err := os.OpenFile(filepath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
 	if err != nil {
@@ -57,15 +99,31 @@ func CreateFile(filepath string) (*DBFile, error) {
 		filepath: filepath,
 		header: FileHeader{
 			MagicNumber: DBFileMagicNumber,
-			Version:     1,
+			Version:     FileVersionCurrent,
 			PageCount:   1, // Account for header page
 			FirstFree:   0,
 			RootPage:    0,
 		},
+		openSnapshots: make(map[uint32]int),
+		pendingFree:   make(map[uint32][]uint32),
+		retiredMmaps:  make(map[uint32][][]byte),
+	}
+	for _, opt := range opts {
+		opt(dbFile)
+	}
+	dbFile.segMgr = newSegManagerForOpts(dbFile, file, filepath)
+
+	wal, err := openWAL(filepath)
+	if err != nil {
+		file.Close()
+		os.Remove(filepath)
+		return nil, err
 	}
+	dbFile.wal = wal
 
 	// Write the initial file header
 	if err := dbFile.writeHeader(); err != nil {
+		wal.close()
 		file.Close()
 		os.Remove(filepath)
 		return nil, err
@@ -74,18 +132,41 @@ func CreateFile(filepath string) (*DBFile, error) {
 	return dbFile, nil
 }
 
-// OpenFile opens an existing database file
-func OpenFile(filepath string) (*DBFile, error) {
-	// Open existing file with read/write permissions
-	file, err := os.OpenFile(filepath, os.O_RDWR, 0666)
+// OpenFile opens an existing database file for reading and writing
+func OpenFile(filepath string, opts ...DBFileOption) (*DBFile, error) {
+	return openFile(filepath, false, opts...)
+}
+
+// OpenFileReadOnly opens an existing database file without acquiring
+// write access. Tx.Begin(true) on the returned DBFile always fails.
+func OpenFileReadOnly(filepath string, opts ...DBFileOption) (*DBFile, error) {
+	return openFile(filepath, true, opts...)
+}
+
+func openFile(filepath string, readOnly bool, opts ...DBFileOption) (*DBFile, error) {
+	flag := os.O_RDWR
+	if readOnly {
+		flag = os.O_RDONLY
+	}
+
+	// Open existing file
+	file, err := os.OpenFile(filepath, flag, 0666)
 	if err != nil {
 		return nil, err
 	}
 
 	dbFile := &DBFile{
-		file:     file,
-		filepath: filepath,
+		file:          file,
+		filepath:      filepath,
+		readOnly:      readOnly,
+		openSnapshots: make(map[uint32]int),
+		pendingFree:   make(map[uint32][]uint32),
+		retiredMmaps:  make(map[uint32][][]byte),
+	}
+	for _, opt := range opts {
+		opt(dbFile)
 	}
+	dbFile.segMgr = newSegManagerForOpts(dbFile, file, filepath)
 
 	// Read and validate the file header
 	if err := dbFile.readHeader(); err != nil {
@@ -99,9 +180,80 @@ func OpenFile(filepath string) (*DBFile, error) {
 		return nil, ErrInvalidFile
 	}
 
+	if readOnly {
+		return dbFile, nil
+	}
+
+	wal, err := openWAL(filepath)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	dbFile.wal = wal
+
+	// Replay any WAL records written after the last checkpoint so the
+	// file is consistent even if the process crashed mid-write.
+	if err := wal.replay(dbFile); err != nil {
+		wal.close()
+		file.Close()
+		return nil, err
+	}
+
+	// A checkpoint truncates the WAL, so its records alone would restart
+	// LSNs at 0; keep numbering past CheckpointLSN so replay never skips
+	// records appended after this open.
+	if wal.nextLSN <= dbFile.header.CheckpointLSN {
+		wal.nextLSN = dbFile.header.CheckpointLSN + 1
+	}
+
+	// A v1 file only needs this once: migrateFromV1 rewrites every page
+	// into the current layout up front so readPageFromDiskLocked never
+	// has to tell apart a v1 and a v2 page within the same file (a page
+	// written after this open would be v2 regardless of header.Version,
+	// so the alternative of deciding per-page has no reliable signal).
+	if dbFile.header.Version == FileVersionV1 {
+		if err := dbFile.migrateFromV1(); err != nil {
+			wal.close()
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if err := dbFile.writeHeader(); err != nil {
+		wal.close()
+		file.Close()
+		return nil, err
+	}
+
 	return dbFile, nil
 }
 
+// migrateFromV1 rewrites every existing page out of the pre-compression
+// v1 layout and into the current one, then advances header.Version so
+// this only ever runs once per file. Starts at page 1: page 0 is the
+// reserved header page CreateFile's initial PageCount accounts for and
+// was never actually written, so under v1 it decodes as an all-zero,
+// fully-"live" page with no room left for the length prefix the current
+// layout always writes; trying to re-serialize it would fail with
+// ErrPageFull for no real reason. Called from openFile before the DBFile
+// is handed to any caller, so it needs no locking of its own;
+// readPage/writePage take care of their own locking same as any other
+// caller.
+func (df *DBFile) migrateFromV1() error {
+	for pageNum := uint32(1); pageNum < df.header.PageCount; pageNum++ {
+		page, err := df.readPage(pageNum)
+		if err != nil {
+			continue
+		}
+		if err := df.writePage(page); err != nil {
+			return err
+		}
+	}
+
+	df.header.Version = FileVersionCurrent
+	return nil
+}
+
 // AllocatePage allocates a new page or reuses a free page
 func (df *DBFile) AllocatePage(pageType PageType) (*Page, error) {
 	df.mutex.Lock()
@@ -114,8 +266,9 @@ func (df *DBFile) AllocatePage(pageType PageType) (*Page, error) {
 		// Reuse a free page
 		pageNum = df.header.FirstFree
 
-		// Read the free page to get the next free page number
-		freePage, err := df.readPage(pageNum)
+		// Read the free page to get the next free page number. Uses the
+		// Locked variant since this function already holds df.mutex.
+		freePage, err := df.readPageLocked(pageNum)
 		if err != nil {
 			return nil, err
 		}
@@ -126,13 +279,23 @@ func (df *DBFile) AllocatePage(pageType PageType) (*Page, error) {
 		// Allocate a new page at the end of the file
 		pageNum = df.header.PageCount
 		df.header.PageCount++
+
+		if err := df.remapLocked(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create new page
 	page := NewPage(pageNum, pageType)
 
-	// Write the page to disk
-	if err := df.writePage(page); err != nil {
+	// Write the page to disk. When a buffer pool is configured the fresh
+	// frame is inserted pinned, protecting it from an eviction sweep
+	// triggered by some other page while this page is still being handed
+	// back to the caller; callers that hold onto the returned page past
+	// this call should release the pin with UnpinPage once they are done
+	// with it so the pool can reclaim it later. Uses the Locked variant
+	// since this function already holds df.mutex.
+	if err := df.writePagePinnedLocked(page, df.pool != nil); err != nil {
 		return nil, err
 	}
 
@@ -144,52 +307,237 @@ func (df *DBFile) AllocatePage(pageType PageType) (*Page, error) {
 	return page, nil
 }
 
-// ReadPage reads a page from disk
+// ReadPage reads a page from disk, consulting the buffer pool first when
+// one is configured.
 func (df *DBFile) readPage(pageNum uint32) (*Page, error) {
+	if df.pool != nil {
+		if page, ok := df.pool.get(pageNum); ok {
+			return page, nil
+		}
+	}
+
+	page, err := df.readPageFromDisk(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	if df.pool != nil {
+		// Insert outside readPageFromDisk's RLock: an eviction this
+		// triggers writes back a *different*, dirty frame and must be
+		// free to take df.mutex for writing (e.g. to open a new
+		// segment), which an RLock held by this call would deadlock
+		// against. Best-effort: a failed write-back is reported to
+		// whichever writer next flushes that frame, not to this read.
+		df.pool.put(pageNum, page, false, false, df.writeBack)
+	}
+
+	return page, nil
+}
+
+// readPageLocked is readPage for a caller that already holds df.mutex
+// (AllocatePage, reusing a page off the free list): it consults the pool
+// the same way, but writes back an evicted dirty frame via writeBackLocked
+// instead of the self-locking writeBack, since taking df.mutex again here
+// would deadlock against the lock the caller is already holding.
+func (df *DBFile) readPageLocked(pageNum uint32) (*Page, error) {
+	if df.pool != nil {
+		if page, ok := df.pool.get(pageNum); ok {
+			return page, nil
+		}
+	}
+
+	page, err := df.readPageFromDiskLocked(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	if df.pool != nil {
+		df.pool.put(pageNum, page, false, false, df.writeBackLocked)
+	}
+
+	return page, nil
+}
+
+// readPageFromDisk reads and deserializes pageNum straight from its
+// segment (or the mmap, when one covers it), bypassing the buffer pool.
+func (df *DBFile) readPageFromDisk(pageNum uint32) (*Page, error) {
 	df.mutex.RLock()
 	defer df.mutex.RUnlock()
 
+	return df.readPageFromDiskLocked(pageNum)
+}
+
+// readPageFromDiskLocked is readPageFromDisk's body for a caller that
+// already holds df.mutex, for reading or writing.
+func (df *DBFile) readPageFromDiskLocked(pageNum uint32) (*Page, error) {
 	if pageNum >= df.header.PageCount {
 		return nil, ErrPageNotFound
 	}
 
-	// Calculate page offset in file
-	offset := int64(FileHeaderSize) + (int64(pageNum) * int64(PageSize))
+	segIdx, offset := df.segMgr.locate(pageNum)
 
-	// Read page data
-	buf := make([]byte, PageSize)
-	_, err := df.file.ReadAt(buf, offset)
-	if err != nil {
-		return nil, err
+	// Read page data, aliasing the mapping when one covers this offset
+	// instead of copying it in with ReadAt. Only segment 0 is ever mapped.
+	var buf, raw []byte
+	if segIdx == 0 && df.mmapEnabled && offset+int64(PageSize) <= int64(df.mmapLen) {
+		raw = df.mmap[offset : offset+int64(PageSize) : offset+int64(PageSize)]
+		buf = raw
+	} else {
+		f, err := df.segMgr.openForRead(segIdx)
+		if err != nil {
+			return nil, err
+		}
+		buf = make([]byte, PageSize)
+		if _, err := f.ReadAt(buf, offset); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create new page and deserialize data
+	// Create new page and deserialize data. A file opened while still at
+	// FileVersionV1 has every existing page in the pre-compression
+	// layout until migrateFromV1 rewrites them, so dispatch on the
+	// header's Version rather than always assuming PageHeaderSize.
 	page := &Page{}
-	if err := page.Deserialize(buf); err != nil {
+	var err error
+	if df.header.Version == FileVersionV1 {
+		err = page.DeserializeV1(buf)
+	} else {
+		err = page.Deserialize(buf)
+	}
+	if err != nil {
 		return nil, err
 	}
+	page.raw = raw
 
 	return page, nil
 }
 
-// WritePage writes a page to disk
+// writePage writes a page to disk, first appending and fsyncing the page
+// image to the WAL so the write is durable even if the process crashes
+// before the main file write completes.
 func (df *DBFile) writePage(page *Page) error {
+	return df.writePagePinned(page, false)
+}
+
+// writePagePinned is writePage with control over whether the buffer-pool
+// frame it inserts starts pinned; only AllocatePage asks for a pinned
+// frame, through writePagePinnedLocked. When no buffer pool is configured the page is written
+// straight through to its segment, exactly as before the pool existed.
+func (df *DBFile) writePagePinned(page *Page, pin bool) error {
 	df.mutex.Lock()
 	defer df.mutex.Unlock()
 
+	return df.writePagePinnedLocked(page, pin)
+}
+
+// writePagePinnedLocked is writePagePinned's body for a caller (AllocatePage,
+// FreePage, mergeReclaimableFreePages) that already holds df.mutex for
+// writing.
+func (df *DBFile) writePagePinnedLocked(page *Page, pin bool) error {
 	if page.GetPageNum() >= df.header.PageCount {
 		return ErrPageNotFound
 	}
 
-	// Calculate page offset in file
-	offset := int64(FileHeaderSize) + (int64(page.GetPageNum()) * int64(PageSize))
+	data, err := page.Serialize(df.compressor)
+	if err != nil {
+		return err
+	}
+
+	var image [PageSize]byte
+	copy(image[:], data)
+
+	if _, err := df.wal.append(0, page.GetPageNum(), image); err != nil {
+		return err
+	}
+
+	if df.pool != nil {
+		// The WAL append above already makes this write crash-safe, so
+		// the segment write itself can be deferred to eviction or an
+		// explicit Flush/Checkpoint.
+		return df.pool.put(page.GetPageNum(), page, true, pin, df.writeBackLocked)
+	}
+
+	return df.writeBackLocked(page)
+}
+
+// writeBackLocked serializes page and writes it to its segment. Callers
+// must already hold df.mutex; the buffer pool's eviction and flush paths
+// only reach it through a caller that holds it (writePagePinnedLocked,
+// readPageLocked, Flush, Checkpoint, Close).
+func (df *DBFile) writeBackLocked(page *Page) error {
+	data, err := page.Serialize(df.compressor)
+	if err != nil {
+		return err
+	}
+
+	segIdx, offset := df.segMgr.locate(page.GetPageNum())
+	f, err := df.segMgr.openForWrite(segIdx)
+	if err != nil {
+		return err
+	}
 
-	// Serialize and write page data
-	data := page.Serialize()
-	_, err := df.file.WriteAt(data, offset)
+	_, err = f.WriteAt(data, offset)
 	return err
 }
 
+// writeBack is writeBackLocked for a caller (readPage) that does not
+// already hold df.mutex: it takes the lock itself before serializing and
+// writing page to its segment.
+func (df *DBFile) writeBack(page *Page) error {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	return df.writeBackLocked(page)
+}
+
+// UnpinPage releases a pin that a buffer-pool-backed AllocatePage placed
+// on pageNum, letting the pool's eviction sweep consider that frame again.
+// A no-op when this DBFile has no buffer pool configured.
+func (df *DBFile) UnpinPage(pageNum uint32) {
+	if df.pool != nil {
+		df.pool.unpin(pageNum)
+	}
+}
+
+// PoolStats returns this DBFile's buffer-pool hit/miss/eviction/dirty
+// counters, or the zero value if no buffer pool is configured.
+func (df *DBFile) PoolStats() PoolStats {
+	if df.pool == nil {
+		return PoolStats{}
+	}
+	return df.pool.Stats()
+}
+
+// Flush writes every dirty buffer-pool frame back to its segment file. A
+// no-op when no buffer pool is configured, since writePage already writes
+// straight through in that case.
+func (df *DBFile) Flush() error {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	if df.pool == nil {
+		return nil
+	}
+	return df.pool.flush(df.writeBackLocked)
+}
+
+// Checkpoint flushes any buffer-pool frames still held back, then flushes
+// the main file to disk, records the LSN of the last applied WAL record in
+// the file header, and truncates the WAL so it does not grow without
+// bound.
+func (df *DBFile) Checkpoint() error {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	if df.pool != nil {
+		if err := df.pool.flush(df.writeBackLocked); err != nil {
+			return err
+		}
+	}
+
+	return df.wal.checkpoint(df)
+}
+
 // FreePage marks a page as free for future reuse
 func (df *DBFile) FreePage(pageNum uint32) error {
 	df.mutex.Lock()
@@ -206,66 +554,162 @@ func (df *DBFile) FreePage(pageNum uint32) error {
 	// Update the first free page pointer
 	df.header.FirstFree = pageNum
 
-	// Write the free page and header
-	if err := df.writePage(freePage); err != nil {
+	// Write the free page and header. Uses the Locked variant since this
+	// function already holds df.mutex.
+	if err := df.writePagePinnedLocked(freePage, false); err != nil {
 		return err
 	}
 	return df.writeHeader()
 }
 
-// writeHeader writes the file header to disk
+// writeHeader bumps the header's Generation and writes it to disk. The
+// slot written to (offset 0 or offset FileHeaderSize) is derived from the
+// new Generation, so successive writes alternate slots: a crash mid-write
+// can tear at most the slot not currently considered active, and the
+// other slot still holds the last complete header.
 func (df *DBFile) writeHeader() error {
-	buf := make([]byte, FileHeaderSize)
+	df.header.Generation++
 
-	// Serialize header fields
-	binary.LittleEndian.PutUint32(buf[0:4], df.header.MagicNumber)
-	binary.LittleEndian.PutUint32(buf[4:8], df.header.Version)
-	binary.LittleEndian.PutUint32(buf[8:12], df.header.PageCount)
-	binary.LittleEndian.PutUint32(buf[12:16], df.header.FirstFree)
-	binary.LittleEndian.PutUint32(buf[16:20], df.header.RootPage)
-	// Reserved bytes are already zero-initialized
+	buf := serializeHeader(df.header)
 
-	_, err := df.file.WriteAt(buf, 0)
+	slot := int64(df.header.Generation % headerSlotCount)
+	_, err := df.file.WriteAt(buf, slot*FileHeaderSize)
 	return err
 }
 
-// readHeader reads the file header from disk
+// readHeader reads both header slots and adopts whichever is valid and
+// has the higher Generation, since that is the most recently completed
+// write.
 func (df *DBFile) readHeader() error {
-	buf := make([]byte, FileHeaderSize)
+	var best *FileHeader
 
-	_, err := df.file.ReadAt(buf, 0)
-	if err != nil {
-		return err
+	for slot := int64(0); slot < headerSlotCount; slot++ {
+		buf := make([]byte, FileHeaderSize)
+		if _, err := df.file.ReadAt(buf, slot*FileHeaderSize); err != nil {
+			continue
+		}
+
+		candidate := deserializeHeader(buf)
+		if candidate.MagicNumber != DBFileMagicNumber {
+			continue
+		}
+		if best == nil || candidate.Generation > best.Generation {
+			best = &candidate
+		}
 	}
 
-	// Deserialize header fields
-	df.header.MagicNumber = binary.LittleEndian.Uint32(buf[0:4])
-	df.header.Version = binary.LittleEndian.Uint32(buf[4:8])
-	df.header.PageCount = binary.LittleEndian.Uint32(buf[8:12])
-	df.header.FirstFree = binary.LittleEndian.Uint32(buf[12:16])
-	df.header.RootPage = binary.LittleEndian.Uint32(buf[16:20])
-	// Skip reserved bytes
+	if best == nil {
+		return ErrInvalidFile
+	}
 
+	df.header = *best
 	return nil
 }
 
+// serializeHeader encodes a FileHeader into a FileHeaderSize buffer.
+func serializeHeader(h FileHeader) []byte {
+	buf := make([]byte, FileHeaderSize)
+
+	binary.LittleEndian.PutUint32(buf[0:4], h.MagicNumber)
+	binary.LittleEndian.PutUint32(buf[4:8], h.Version)
+	binary.LittleEndian.PutUint32(buf[8:12], h.PageCount)
+	binary.LittleEndian.PutUint32(buf[12:16], h.FirstFree)
+	binary.LittleEndian.PutUint32(buf[16:20], h.RootPage)
+	binary.LittleEndian.PutUint64(buf[20:28], h.CheckpointLSN)
+	binary.LittleEndian.PutUint32(buf[28:32], h.Generation)
+
+	return buf
+}
+
+// deserializeHeader decodes a FileHeaderSize buffer into a FileHeader.
+func deserializeHeader(buf []byte) FileHeader {
+	return FileHeader{
+		MagicNumber:   binary.LittleEndian.Uint32(buf[0:4]),
+		Version:       binary.LittleEndian.Uint32(buf[4:8]),
+		PageCount:     binary.LittleEndian.Uint32(buf[8:12]),
+		FirstFree:     binary.LittleEndian.Uint32(buf[12:16]),
+		RootPage:      binary.LittleEndian.Uint32(buf[16:20]),
+		CheckpointLSN: binary.LittleEndian.Uint64(buf[20:28]),
+		Generation:    binary.LittleEndian.Uint32(buf[28:32]),
+	}
+}
+
 // Close closes the database file
 func (df *DBFile) Close() error {
 	df.mutex.Lock()
 	defer df.mutex.Unlock()
 
+	if df.readOnly {
+		if err := df.segMgr.closeExtra(); err != nil {
+			return err
+		}
+		return df.file.Close()
+	}
+
 	// Write any pending changes to the header
 	if err := df.writeHeader(); err != nil {
 		return err
 	}
 
+	if df.pool != nil {
+		if err := df.pool.flush(df.writeBackLocked); err != nil {
+			return err
+		}
+	}
+
+	if err := df.wal.close(); err != nil {
+		return err
+	}
+
+	if err := df.disableMmapLocked(); err != nil {
+		return err
+	}
+
+	if err := df.segMgr.closeExtra(); err != nil {
+		return err
+	}
+
 	return df.file.Close()
 }
 
-// Sync forces any buffered changes to be written to disk
+// Sync forces any buffered changes to be written to disk, across every
+// segment this DBFile has opened.
 func (df *DBFile) Sync() error {
 	df.mutex.Lock()
 	defer df.mutex.Unlock()
 
-	return df.file.Sync()
+	return df.segMgr.syncAll()
+}
+
+// TruncatePagesBefore deletes every segment file whose entire page range
+// falls below pageNum, reclaiming their disk space once nothing in the
+// file still references a page that low. Callers must make sure no live
+// page below pageNum remains (e.g. on the free list or reachable from the
+// B+Tree) before calling this; segmented layout has no way to check that
+// on its own. A no-op on a DBFile opened with WithSingleSegmentFile.
+func (df *DBFile) TruncatePagesBefore(pageNum uint32) error {
+	df.mutex.Lock()
+	defer df.mutex.Unlock()
+
+	return df.segMgr.truncateBefore(pageNum)
+}
+
+// RangePages calls fn with every page from 0 up to the current PageCount,
+// in page-number order, stopping early if fn returns false. A page whose
+// segment TruncatePagesBefore already removed is skipped rather than
+// aborting the scan.
+func (df *DBFile) RangePages(fn func(*Page) bool) {
+	df.mutex.RLock()
+	count := df.header.PageCount
+	df.mutex.RUnlock()
+
+	for pageNum := uint32(0); pageNum < count; pageNum++ {
+		page, err := df.readPage(pageNum)
+		if err != nil {
+			continue
+		}
+		if !fn(page) {
+			return
+		}
+	}
 }
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestOpenFileMigratesV1Pages guards against Deserialize's unconditional
+// v2 layout assumption silently breaking a file created before per-page
+// compression existed: OpenFile on a FileVersionV1 file must decode its
+// existing pages through DeserializeV1, migrate them to the current
+// layout, and leave the file readable by the regular path from then on.
+func TestOpenFileMigratesV1Pages(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "legacy.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	page, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+	pageNum := page.GetPageNum()
+
+	df.header.Version = FileVersionV1
+	if err := df.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Overwrite the page on disk with the pre-compression v1 layout: a
+	// pageHeaderSizeV1-byte header directly followed by the payload, with
+	// no codec dispatch and no compressed-length prefix.
+	payload := []byte("legacy-data")
+	dataLen := PageSize - PageHeaderSize
+	buf := make([]byte, PageSize)
+	buf[0] = byte(PageTypeData)
+	binary.LittleEndian.PutUint32(buf[1:5], pageNum)
+	binary.LittleEndian.PutUint16(buf[5:7], uint16(dataLen-len(payload)))
+	copy(buf[pageHeaderSizeV1:], payload)
+
+	raw, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		t.Fatalf("open raw: %v", err)
+	}
+	if _, err := raw.WriteAt(buf, int64(dataOffset)+int64(pageNum)*int64(PageSize)); err != nil {
+		t.Fatalf("WriteAt: %v", err)
+	}
+	if err := raw.Close(); err != nil {
+		t.Fatalf("close raw: %v", err)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.header.Version != FileVersionCurrent {
+		t.Errorf("expected migrateFromV1 to advance Version to %d, got %d", FileVersionCurrent, reopened.header.Version)
+	}
+
+	got, err := reopened.readPage(pageNum)
+	if err != nil {
+		t.Fatalf("readPage: %v", err)
+	}
+	data, err := got.ReadData(0, uint16(len(payload)))
+	if err != nil {
+		t.Fatalf("ReadData: %v", err)
+	}
+	if string(data) != string(payload) {
+		t.Errorf("expected migrated page to read back %q, got %q", payload, data)
+	}
+}
@@ -0,0 +1,175 @@
+package engine
+
+import "os"
+
+// mmapFile and munmapFile are the platform-specific halves of this file;
+// see mmap_unix.go and mmap_windows.go. mmapFile returning an error (as
+// it always does on platforms without a mapping syscall) is not fatal:
+// every call site here treats it as "keep using the pread path".
+
+// OpenFileMmap opens an existing database file for reading and writing,
+// the same as OpenFile, and additionally memory-maps it read-only so
+// ReadPage can return pages that alias the mapping instead of copying
+// through ReadAt. If mmap isn't available on this platform or the initial
+// mapping call fails, the returned DBFile is still fully usable; it just
+// falls back to the regular pread path transparently.
+func OpenFileMmap(filepath string, opts ...DBFileOption) (*DBFile, error) {
+	df, err := openFile(filepath, false, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	df.mutex.Lock()
+	mapErr := df.enableMmapLocked()
+	df.mutex.Unlock()
+	if mapErr != nil {
+		return df, nil
+	}
+
+	return df, nil
+}
+
+// enableMmapLocked maps the file read-only to cover the current
+// PageCount, rounded up to os.Getpagesize(). Callers must hold df.mutex
+// for writing.
+func (df *DBFile) enableMmapLocked() error {
+	mapLen := roundUpToPageSize(dataOffset + int(df.mmapPageCount())*PageSize)
+
+	data, err := mmapFile(int(df.file.Fd()), mapLen)
+	if err != nil {
+		return err
+	}
+
+	df.mmap = data
+	df.mmapLen = mapLen
+	df.mmapEnabled = true
+	return nil
+}
+
+// remapLocked re-maps the file whenever PageCount has grown past what's
+// already mapped. It is a no-op for a DBFile that was never opened via
+// OpenFileMmap (or whose initial mapping failed), and it must be called
+// with df.mutex held for writing by AllocatePage and Tx.Commit, the two
+// places PageCount can grow.
+//
+// The superseded mapping is not unmapped here: a read transaction begun
+// before this remap may still be holding a Page.RawBytes() slice that
+// aliases it (this is exactly the snapshot isolation Tx is designed to
+// allow — a reader's view of the file must stay valid while a concurrent
+// writer commits and grows it), and munmapping out from under that slice
+// is a use-after-unmap. Instead the old mapping is retired under the
+// Generation still active at the time of this call, the same way
+// FreePage's pages are deferred into pendingFree rather than reused
+// immediately, and reclaimRetiredMmapsLocked actually unmaps it once no
+// open snapshot predates that Generation.
+func (df *DBFile) remapLocked() error {
+	if !df.mmapEnabled {
+		return nil
+	}
+
+	needed := roundUpToPageSize(dataOffset + int(df.mmapPageCount())*PageSize)
+	if needed == df.mmapLen {
+		return nil
+	}
+
+	data, err := mmapFile(int(df.file.Fd()), needed)
+	if err != nil {
+		return err
+	}
+
+	df.retireMmapLocked(df.mmap)
+	df.mmap = data
+	df.mmapLen = needed
+
+	df.reclaimRetiredMmapsLocked()
+	return nil
+}
+
+// retireMmapLocked defers unmapping old to reclaimRetiredMmapsLocked,
+// tagged with the Generation active right now: any snapshot begun
+// against this Generation or earlier may still hold a RawBytes() slice
+// into old. Callers must hold df.mutex for writing.
+func (df *DBFile) retireMmapLocked(old []byte) {
+	if old == nil {
+		return
+	}
+
+	df.snapMu.Lock()
+	df.retiredMmaps[df.header.Generation] = append(df.retiredMmaps[df.header.Generation], old)
+	df.snapMu.Unlock()
+}
+
+// reclaimRetiredMmapsLocked munmaps every retired mapping from a
+// Generation older than every snapshot still open, mirroring
+// mergeReclaimableFreePages' generation bookkeeping. Best effort: a
+// failed munmap leaks that mapping rather than risk unmapping memory a
+// reader might still be using. Callers must hold df.mutex for writing.
+func (df *DBFile) reclaimRetiredMmapsLocked() {
+	df.snapMu.Lock()
+	var oldestOpen uint32
+	hasOpenSnapshot := false
+	for gen := range df.openSnapshots {
+		if !hasOpenSnapshot || gen < oldestOpen {
+			oldestOpen = gen
+			hasOpenSnapshot = true
+		}
+	}
+
+	var reclaimable [][]byte
+	for gen, mappings := range df.retiredMmaps {
+		if hasOpenSnapshot && gen >= oldestOpen {
+			continue
+		}
+		reclaimable = append(reclaimable, mappings...)
+		delete(df.retiredMmaps, gen)
+	}
+	df.snapMu.Unlock()
+
+	for _, mapping := range reclaimable {
+		munmapFile(mapping)
+	}
+}
+
+// disableMmapLocked unmaps the file, if mapped, along with any mappings
+// still waiting on reclaimRetiredMmapsLocked. Called from Close, by
+// which point every Tx should have released its snapshot, but a caller
+// that leaked one open should not leak memory on top of it.
+func (df *DBFile) disableMmapLocked() error {
+	df.snapMu.Lock()
+	for gen, mappings := range df.retiredMmaps {
+		for _, mapping := range mappings {
+			munmapFile(mapping)
+		}
+		delete(df.retiredMmaps, gen)
+	}
+	df.snapMu.Unlock()
+
+	if !df.mmapEnabled {
+		return nil
+	}
+
+	if err := munmapFile(df.mmap); err != nil {
+		return err
+	}
+	df.mmap = nil
+	df.mmapEnabled = false
+	return nil
+}
+
+// mmapPageCount returns how many of the file's pages live in segment 0,
+// the only segment OpenFileMmap ever maps; pages that spill into later
+// segments are always served through the pread fallback in readPage.
+func (df *DBFile) mmapPageCount() uint32 {
+	if df.segMgr.maxSegmentSize == 0 {
+		return df.header.PageCount
+	}
+	if capacity := df.segMgr.seg0Capacity(); df.header.PageCount > capacity {
+		return capacity
+	}
+	return df.header.PageCount
+}
+
+func roundUpToPageSize(n int) int {
+	pageSize := os.Getpagesize()
+	return (n + pageSize - 1) / pageSize * pageSize
+}
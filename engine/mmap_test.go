@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenFileMmapServesRawBytes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mmap.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	page, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+	if err := page.WriteData(0, []byte("hello")); err != nil {
+		t.Fatalf("WriteData: %v", err)
+	}
+	if err := df.writePage(page); err != nil {
+		t.Fatalf("writePage: %v", err)
+	}
+	if err := df.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mapped, err := OpenFileMmap(path)
+	if err != nil {
+		t.Fatalf("OpenFileMmap: %v", err)
+	}
+	defer mapped.Close()
+
+	got, err := mapped.readPage(page.GetPageNum())
+	if err != nil {
+		t.Fatalf("readPage: %v", err)
+	}
+
+	raw := got.RawBytes()
+	if mapped.mmapEnabled && raw == nil {
+		t.Fatalf("expected RawBytes to alias the mapping once mmap is enabled")
+	}
+	if raw != nil && len(raw) != PageSize {
+		t.Errorf("expected RawBytes to be PageSize long, got %d", len(raw))
+	}
+
+	data, err := got.ReadData(0, 5)
+	if err != nil {
+		t.Fatalf("ReadData: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", data)
+	}
+}
+
+func TestOpenFileMmapRemapsOnGrowth(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "grow.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	df.Close()
+
+	mapped, err := OpenFileMmap(path)
+	if err != nil {
+		t.Fatalf("OpenFileMmap: %v", err)
+	}
+	defer mapped.Close()
+
+	if !mapped.mmapEnabled {
+		t.Skip("mmap not available on this platform")
+	}
+
+	initialLen := mapped.mmapLen
+
+	var last *Page
+	for i := 0; i < 64; i++ {
+		last, err = mapped.AllocatePage(PageTypeData)
+		if err != nil {
+			t.Fatalf("AllocatePage: %v", err)
+		}
+	}
+
+	if mapped.mmapLen <= initialLen {
+		t.Fatalf("expected mapping to grow past %d bytes, got %d", initialLen, mapped.mmapLen)
+	}
+
+	got, err := mapped.readPage(last.GetPageNum())
+	if err != nil {
+		t.Fatalf("readPage after growth: %v", err)
+	}
+	if got.RawBytes() == nil {
+		t.Errorf("expected the freshly-remapped page to still alias the mapping")
+	}
+}
+
+// TestRawBytesSurvivesConcurrentGrowth guards against remapLocked
+// munmapping a mapping that an open read snapshot's Page.RawBytes() slice
+// still aliases: exactly the scenario the Tx snapshot isolation is meant
+// to allow (a reader holds a stable view while a writer grows the file).
+func TestRawBytesSurvivesConcurrentGrowth(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "growth-snapshot.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	page, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+	if err := df.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mapped, err := OpenFileMmap(path)
+	if err != nil {
+		t.Fatalf("OpenFileMmap: %v", err)
+	}
+	defer mapped.Close()
+	if !mapped.mmapEnabled {
+		t.Skip("mmap not available on this platform")
+	}
+
+	readTx, err := mapped.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin(false): %v", err)
+	}
+
+	snapshotPage, err := readTx.GetPage(page.GetPageNum())
+	if err != nil {
+		t.Fatalf("GetPage: %v", err)
+	}
+	raw := snapshotPage.RawBytes()
+	if raw == nil {
+		t.Fatalf("expected RawBytes to alias the mapping")
+	}
+	before := append([]byte(nil), raw...)
+
+	mappingBeforeGrowth := mapped.mmap
+
+	// Grow the file enough, with readTx's snapshot still open, to force
+	// AllocatePage's remapLocked to replace the mapping raw aliases.
+	for i := 0; i < 64; i++ {
+		if _, err := mapped.AllocatePage(PageTypeData); err != nil {
+			t.Fatalf("AllocatePage: %v", err)
+		}
+	}
+
+	mapped.mutex.RLock()
+	remapped := len(mapped.mmap) > 0 && &mapped.mmap[0] != &mappingBeforeGrowth[0]
+	mapped.mutex.RUnlock()
+	if !remapped {
+		t.Fatalf("expected growth to trigger a remap")
+	}
+
+	// Reading raw now, with the old mapping superseded, is only safe
+	// because reclaimRetiredMmapsLocked must not have unmapped it while
+	// readTx's Generation is still open: a real use-after-unmap here
+	// would corrupt these bytes or crash the process outright, not
+	// return a mismatch.
+	if !bytes.Equal(raw, before) {
+		t.Errorf("expected the retired mapping's bytes to be unchanged, got %x want %x", raw, before)
+	}
+
+	if err := readTx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
+// BenchmarkReadPagePread reads the same page repeatedly through the regular
+// ReadAt path, as a baseline for BenchmarkReadPageMmap below.
+func BenchmarkReadPagePread(b *testing.B) {
+	dir := b.TempDir()
+	path := filepath.Join(dir, "bench_pread.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		b.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	page, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		b.Fatalf("AllocatePage: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := df.readPage(page.GetPageNum()); err != nil {
+			b.Fatalf("readPage: %v", err)
+		}
+	}
+}
+
+// BenchmarkReadPageMmap reads the same page repeatedly against a DBFile
+// opened via OpenFileMmap, aliasing the mapping instead of copying through
+// ReadAt.
+func BenchmarkReadPageMmap(b *testing.B) {
+	dir := b.TempDir()
+	path := filepath.Join(dir, "bench_mmap.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		b.Fatalf("CreateFile: %v", err)
+	}
+	page, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		b.Fatalf("AllocatePage: %v", err)
+	}
+	df.Close()
+
+	mapped, err := OpenFileMmap(path)
+	if err != nil {
+		b.Fatalf("OpenFileMmap: %v", err)
+	}
+	defer mapped.Close()
+	if !mapped.mmapEnabled {
+		b.Skip("mmap not available on this platform")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mapped.readPage(page.GetPageNum()); err != nil {
+			b.Fatalf("readPage: %v", err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package engine
+
+import "syscall"
+
+// mmapFile maps length bytes of fd read-only and shared, so every DBFile
+// mapping the same file observes the same pages.
+func mmapFile(fd int, length int) ([]byte, error) {
+	return syscall.Mmap(fd, 0, length, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
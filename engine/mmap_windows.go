@@ -0,0 +1,18 @@
+//go:build windows
+
+package engine
+
+import "errors"
+
+// errMmapUnsupported is returned by mmapFile on Windows, where this package
+// has no mapping syscall wired up. OpenFileMmap treats this the same as any
+// other mapping failure: the DBFile stays fully usable on the pread path.
+var errMmapUnsupported = errors.New("engine: mmap not supported on this platform")
+
+func mmapFile(fd int, length int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
@@ -8,11 +8,31 @@ import (
 const (
 	PageSize = 4096
 
-	PageHeaderSize = 16
+	// PageHeaderSize covers the fixed fields below plus the 3-byte codec
+	// ID and uncompressed-length pair compression stole from the page
+	// layout; it does not include the compressed-length prefix that
+	// precedes the payload, which Serialize always writes and which is
+	// counted against the data region instead.
+	PageHeaderSize = 24
+
+	// compressedLenPrefixSize is the uint32 length prefix written ahead
+	// of the (possibly compressed) payload, inside the data region, so
+	// Deserialize knows exactly how many bytes to hand the codec even
+	// though the rest of the page buffer is zero-padded.
+	compressedLenPrefixSize = 4
+
+	// pageHeaderSizeV1 is the on-disk header size written under
+	// FileVersionV1, before CodecID and UncompressedLen existed: just
+	// the six original fields (PageType, PageNum, FreeSpace, NumRecords,
+	// NextPage, LastUpdated), with no compressed-length prefix ahead of
+	// the payload either. DeserializeV1 uses this instead of
+	// PageHeaderSize to read a page written before compression existed.
+	pageHeaderSizeV1 = 21
 )
 
 var (
-	ErrPageFull = errors.New("page is full")
+	ErrPageFull      = errors.New("page is full")
+	ErrPageCorrupted = errors.New("page payload is corrupted")
 )
 
 type PageType byte
@@ -24,17 +44,24 @@ const (
 )
 
 type PageHeader struct {
-	PageType    PageType
-	PageNum     uint32
-	FreeSpace   uint16
-	NumRecords  uint16
-	NextPage    uint32
-	LastUpdated uint64
+	PageType        PageType
+	PageNum         uint32
+	FreeSpace       uint16
+	NumRecords      uint16
+	NextPage        uint32
+	LastUpdated     uint64
+	CodecID         byte   // Compressor used to produce the serialized payload; 0 is CodecIdentity
+	UncompressedLen uint16 // Length of the live (len(data)-FreeSpace) bytes before compression
 }
 
 type Page struct {
 	header PageHeader
 	data   [PageSize - PageHeaderSize]byte
+
+	// raw aliases the PageSize region of a DBFile's mmap this page was
+	// read from, set by readPage. It is nil for a freshly allocated page
+	// or one read through the pread fallback.
+	raw []byte
 }
 
 func NewPage(pageNum uint32, pageType PageType) *Page {
@@ -70,7 +97,29 @@ func (p *Page) ReadData(offset uint16, length uint16) ([]byte, error) {
 	return result, nil
 }
 
-func (p *Page) Serialize() []byte {
+// Serialize encodes the page header and data into a PageSize buffer. When
+// c is non-nil, the live portion of p.data (everything not covered by
+// FreeSpace) is run through c first and the result is length-prefixed
+// into the data region; a nil c stores the page uncompressed under
+// CodecIdentity. Returns ErrPageFull if the compressed payload, plus its
+// length prefix, can't fit in the space a raw page would have used.
+func (p *Page) Serialize(c Compressor) ([]byte, error) {
+	if c == nil {
+		c = identityCompressor{}
+	}
+
+	liveLen := len(p.data) - int(p.header.FreeSpace)
+	compressed, err := c.Compress(p.data[:liveLen])
+	if err != nil {
+		return nil, err
+	}
+	if compressedLenPrefixSize+len(compressed) > len(p.data) {
+		return nil, ErrPageFull
+	}
+
+	p.header.CodecID = c.ID()
+	p.header.UncompressedLen = uint16(liveLen)
+
 	buf := make([]byte, PageSize)
 
 	buf[0] = byte(p.header.PageType)
@@ -78,13 +127,19 @@ func (p *Page) Serialize() []byte {
 	binary.LittleEndian.PutUint16(buf[5:7], p.header.FreeSpace)
 	binary.LittleEndian.PutUint16(buf[7:9], p.header.NumRecords)
 	binary.LittleEndian.PutUint32(buf[9:13], p.header.NextPage)
-	binary.LittleEndian.PutUint64(buf[13:PageHeaderSize], p.header.LastUpdated)
+	binary.LittleEndian.PutUint64(buf[13:21], p.header.LastUpdated)
+	buf[21] = p.header.CodecID
+	binary.LittleEndian.PutUint16(buf[22:24], p.header.UncompressedLen)
 
-	copy(buf[PageHeaderSize:], p.data[:])
+	binary.LittleEndian.PutUint32(buf[PageHeaderSize:PageHeaderSize+compressedLenPrefixSize], uint32(len(compressed)))
+	copy(buf[PageHeaderSize+compressedLenPrefixSize:], compressed)
 
-	return buf
+	return buf, nil
 }
 
+// Deserialize decodes a PageSize buffer produced by Serialize, dispatching
+// decompression by the CodecID recorded in the header so it works
+// regardless of which codec (if any) this process has configured.
 func (p *Page) Deserialize(data []byte) error {
 	if len(data) != PageSize {
 		return errors.New("invalid page size")
@@ -95,13 +150,69 @@ func (p *Page) Deserialize(data []byte) error {
 	p.header.FreeSpace = binary.LittleEndian.Uint16(data[5:7])
 	p.header.NumRecords = binary.LittleEndian.Uint16(data[7:9])
 	p.header.NextPage = binary.LittleEndian.Uint32(data[9:13])
-	p.header.LastUpdated = binary.LittleEndian.Uint64(data[13:PageHeaderSize])
+	p.header.LastUpdated = binary.LittleEndian.Uint64(data[13:21])
+	p.header.CodecID = data[21]
+	p.header.UncompressedLen = binary.LittleEndian.Uint16(data[22:24])
+
+	codec, err := lookupCodec(p.header.CodecID)
+	if err != nil {
+		return err
+	}
+
+	compressedLen := binary.LittleEndian.Uint32(data[PageHeaderSize : PageHeaderSize+compressedLenPrefixSize])
+	payloadStart := PageHeaderSize + compressedLenPrefixSize
+	if int(compressedLen) > len(data)-payloadStart {
+		return ErrPageCorrupted
+	}
+
+	decompressed, err := codec.Decompress(data[payloadStart : payloadStart+int(compressedLen)])
+	if err != nil {
+		return err
+	}
+	if len(decompressed) != int(p.header.UncompressedLen) || len(decompressed) > len(p.data) {
+		return ErrPageCorrupted
+	}
+
+	copy(p.data[:], decompressed)
+
+	return nil
+}
+
+// DeserializeV1 decodes a PageSize buffer written under FileVersionV1:
+// the header ends at pageHeaderSizeV1 rather than PageHeaderSize, and
+// whatever follows it is the page's data as-is, with no codec dispatch
+// and no compressed-length prefix. Callers use this instead of
+// Deserialize when the owning DBFile's header.Version is FileVersionV1;
+// readPageFromDiskLocked is the only caller.
+func (p *Page) DeserializeV1(data []byte) error {
+	if len(data) != PageSize {
+		return errors.New("invalid page size")
+	}
+
+	p.header.PageType = PageType(data[0])
+	p.header.PageNum = binary.LittleEndian.Uint32(data[1:5])
+	p.header.FreeSpace = binary.LittleEndian.Uint16(data[5:7])
+	p.header.NumRecords = binary.LittleEndian.Uint16(data[7:9])
+	p.header.NextPage = binary.LittleEndian.Uint32(data[9:13])
+	p.header.LastUpdated = binary.LittleEndian.Uint64(data[13:21])
+	p.header.CodecID = CodecIdentity
+	p.header.UncompressedLen = uint16(len(p.data) - int(p.header.FreeSpace))
 
-	copy(p.data[:], data[PageHeaderSize:])
+	copy(p.data[:], data[pageHeaderSizeV1:])
 
 	return nil
 }
 
+// RawBytes returns this page's on-disk image aliasing the DBFile's memory
+// mapping, with no copy, for read transactions against a DBFile opened via
+// OpenFileMmap. It returns nil when the page wasn't sourced from a mapping
+// (the pread fallback was used, or the page is newly allocated and not yet
+// persisted). Callers must not mutate the returned slice: it is shared with
+// every other reader of the same mapped page.
+func (p *Page) RawBytes() []byte {
+	return p.raw
+}
+
 func (p *Page) GetFreeSpace() uint16 {
 	return p.header.FreeSpace
 }
@@ -109,3 +220,21 @@ func (p *Page) GetFreeSpace() uint16 {
 func (p *Page) GetPageNum() uint32 {
 	return p.header.PageNum
 }
+
+// Type returns this page's PageType, as given to NewPage or read back by
+// Deserialize.
+func (p *Page) Type() PageType {
+	return p.header.PageType
+}
+
+// NextPage returns the page number this page's header chains to: the next
+// free page on the free list, or, reused by callers like the B+Tree index,
+// the next leaf in a key-ordered chain. Zero means no next page.
+func (p *Page) NextPage() uint32 {
+	return p.header.NextPage
+}
+
+// SetNextPage sets the page number this page's header chains to.
+func (p *Page) SetNextPage(pageNum uint32) {
+	p.header.NextPage = pageNum
+}
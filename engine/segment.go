@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+)
+
+// DefaultMaxSegmentSize is the rollover threshold for a segmented DBFile:
+// once the active segment would grow past this many bytes, new pages spill
+// into the next sibling segment file instead of growing it further.
+const DefaultMaxSegmentSize int64 = 1 << 30 // 1 GiB
+
+// WithMaxSegmentSize overrides DefaultMaxSegmentSize as the point at which
+// a segmented DBFile rolls new pages into the next sibling segment file.
+// Has no effect on a DBFile opened with WithSingleSegmentFile.
+func WithMaxSegmentSize(size int64) DBFileOption {
+	return func(df *DBFile) {
+		df.maxSegmentSize = size
+	}
+}
+
+// WithSingleSegmentFile disables segmentation, keeping the original
+// single-file layout regardless of how large the file grows. This is the
+// layout every DBFile used before segmentation existed, kept available for
+// files and tooling that assume one file holds every page.
+func WithSingleSegmentFile() DBFileOption {
+	return func(df *DBFile) {
+		df.singleSegment = true
+	}
+}
+
+// segmentSuffix renders sibling segment index i (i is always >= 1; segment
+// 0 is the DBFile's own file and is never suffixed) as "<basePath>.%04d".
+func segmentSuffix(basePath string, i int) string {
+	return fmt.Sprintf("%s.%04d", basePath, i)
+}
+
+// segment is one file backing a contiguous range of page numbers.
+type segment struct {
+	file *os.File
+
+	// readOnly is set once a later segment becomes the head. It marks
+	// that no *new* pages are allocated here; a page already in this
+	// segment can still be rewritten in place (e.g. FreePage marking it
+	// free), so this is advisory bookkeeping, not an OS-level permission.
+	readOnly bool
+}
+
+// segmentManager maps a global page number to the segment file that holds
+// it and the byte offset within that segment, opening sibling segment
+// files lazily as the head segment fills up. Segment 0 is always the
+// DBFile's own file (it carries the header and sits alongside the WAL);
+// newSegmentManager seeds segments[0] with that handle and only ever
+// creates segments 1 and up itself.
+type segmentManager struct {
+	basePath string
+
+	maxSegmentSize  int64  // 0 disables rollover: every page lives in segment 0
+	pagesPerSegment uint32 // page capacity of segments 1+; 0 when maxSegmentSize is 0
+
+	segments []*segment // segments[i] is nil once TruncatePagesBefore removes it
+}
+
+// newSegManagerForOpts builds dbFile's segment manager once its opts have
+// been applied, resolving the default segment size and the
+// WithSingleSegmentFile override.
+func newSegManagerForOpts(dbFile *DBFile, file *os.File, basePath string) *segmentManager {
+	size := dbFile.maxSegmentSize
+	switch {
+	case dbFile.singleSegment:
+		size = 0
+	case size <= 0:
+		size = DefaultMaxSegmentSize
+	}
+	return newSegmentManager(basePath, file, size)
+}
+
+func newSegmentManager(basePath string, head *os.File, maxSegmentSize int64) *segmentManager {
+	sm := &segmentManager{
+		basePath:       basePath,
+		maxSegmentSize: maxSegmentSize,
+		segments:       []*segment{{file: head}},
+	}
+	if maxSegmentSize > 0 {
+		sm.pagesPerSegment = uint32(maxSegmentSize / PageSize)
+	}
+	return sm
+}
+
+// seg0Capacity returns how many pages fit in segment 0 before later pages
+// spill into segment 1. Only meaningful when maxSegmentSize > 0.
+func (sm *segmentManager) seg0Capacity() uint32 {
+	return uint32((sm.maxSegmentSize - int64(dataOffset)) / PageSize)
+}
+
+// locate maps a global page number to the segment index that holds it and
+// the byte offset of that page within the segment's file.
+func (sm *segmentManager) locate(pageNum uint32) (segIdx int, offset int64) {
+	if sm.maxSegmentSize == 0 {
+		return 0, int64(dataOffset) + int64(pageNum)*int64(PageSize)
+	}
+
+	seg0Capacity := sm.seg0Capacity()
+	if pageNum < seg0Capacity {
+		return 0, int64(dataOffset) + int64(pageNum)*int64(PageSize)
+	}
+
+	rem := pageNum - seg0Capacity
+	segIdx = 1 + int(rem/sm.pagesPerSegment)
+	offset = int64(rem%sm.pagesPerSegment) * int64(PageSize)
+	return segIdx, offset
+}
+
+// openForRead returns the file backing segIdx, or ErrPageNotFound if that
+// segment was never created or has since been removed by
+// TruncatePagesBefore.
+func (sm *segmentManager) openForRead(segIdx int) (*os.File, error) {
+	if segIdx >= len(sm.segments) || sm.segments[segIdx] == nil {
+		return nil, ErrPageNotFound
+	}
+	return sm.segments[segIdx].file, nil
+}
+
+// openForWrite returns the file backing segIdx, creating segments up to
+// and including segIdx if this is the first write to reach them. Creating
+// a new segment marks the previous one read-only: the head only ever
+// advances forward. Writing to a segment TruncatePagesBefore already
+// removed is a caller error and reports file corruption, since it means a
+// page the caller believed truncated is being written again.
+func (sm *segmentManager) openForWrite(segIdx int) (*os.File, error) {
+	if segIdx < len(sm.segments) {
+		seg := sm.segments[segIdx]
+		if seg == nil {
+			return nil, ErrFileCorrupted
+		}
+		return seg.file, nil
+	}
+
+	for len(sm.segments) <= segIdx {
+		next := len(sm.segments)
+		f, err := os.OpenFile(segmentSuffix(sm.basePath, next), os.O_RDWR|os.O_CREATE, 0666)
+		if err != nil {
+			return nil, err
+		}
+		if prev := sm.segments[next-1]; prev != nil {
+			prev.readOnly = true
+		}
+		sm.segments = append(sm.segments, &segment{file: f})
+	}
+
+	return sm.segments[segIdx].file, nil
+}
+
+// truncateBefore deletes every segment (other than segment 0, which holds
+// the header) whose entire page range falls below pageNum, reclaiming
+// their disk space. It stops at the first segment that might still hold a
+// page >= pageNum, since segments are contiguous. Callers are responsible
+// for knowing no page below pageNum is still referenced; the segment
+// manager has no way to check that on its own.
+func (sm *segmentManager) truncateBefore(pageNum uint32) error {
+	if sm.maxSegmentSize == 0 {
+		return nil
+	}
+
+	seg0Capacity := sm.seg0Capacity()
+
+	for segIdx := 1; segIdx < len(sm.segments); segIdx++ {
+		seg := sm.segments[segIdx]
+		if seg == nil {
+			continue
+		}
+
+		rangeEnd := seg0Capacity + uint32(segIdx)*sm.pagesPerSegment
+		if rangeEnd > pageNum {
+			break
+		}
+
+		path := seg.file.Name()
+		if err := seg.file.Close(); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		sm.segments[segIdx] = nil
+	}
+
+	return nil
+}
+
+// syncAll fsyncs every segment file this manager has opened, including
+// segment 0, the DBFile's own handle.
+func (sm *segmentManager) syncAll() error {
+	for _, seg := range sm.segments {
+		if seg == nil {
+			continue
+		}
+		if err := seg.file.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeExtra closes every segment file beyond segment 0, which the DBFile
+// closes itself since it owns that handle independently of segmentManager.
+func (sm *segmentManager) closeExtra() error {
+	for segIdx := 1; segIdx < len(sm.segments); segIdx++ {
+		seg := sm.segments[segIdx]
+		if seg == nil {
+			continue
+		}
+		if err := seg.file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// smallSegmentSize caps segment 0 at exactly two pages (page 0, reserved by
+// CreateFile, plus one more), so a third allocated page always spills into
+// segment 1.
+const smallSegmentSize = int64(dataOffset) + 2*PageSize
+
+func TestSegmentManagerRollsOverPastMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "seg.db")
+
+	df, err := CreateFile(path, WithMaxSegmentSize(smallSegmentSize))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	if _, err := df.AllocatePage(PageTypeData); err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+	overflow, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		t.Fatalf("AllocatePage (overflow): %v", err)
+	}
+
+	if _, err := os.Stat(segmentSuffix(path, 1)); err != nil {
+		t.Fatalf("expected sibling segment file to exist, stat failed: %v", err)
+	}
+
+	if err := overflow.WriteData(0, []byte("spilled")); err != nil {
+		t.Fatalf("WriteData: %v", err)
+	}
+	if err := df.writePage(overflow); err != nil {
+		t.Fatalf("writePage: %v", err)
+	}
+
+	got, err := df.readPage(overflow.GetPageNum())
+	if err != nil {
+		t.Fatalf("readPage: %v", err)
+	}
+	data, err := got.ReadData(0, 7)
+	if err != nil {
+		t.Fatalf("ReadData: %v", err)
+	}
+	if string(data) != "spilled" {
+		t.Errorf("expected %q, got %q", "spilled", data)
+	}
+}
+
+func TestWithSingleSegmentFileDisablesRollover(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "single.db")
+
+	df, err := CreateFile(path, WithMaxSegmentSize(smallSegmentSize), WithSingleSegmentFile())
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := df.AllocatePage(PageTypeData); err != nil {
+			t.Fatalf("AllocatePage: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(segmentSuffix(path, 1)); !os.IsNotExist(err) {
+		t.Errorf("expected no sibling segment file under WithSingleSegmentFile, stat err: %v", err)
+	}
+}
+
+func TestTruncatePagesBeforeRemovesOldSegments(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "truncate.db")
+
+	df, err := CreateFile(path, WithMaxSegmentSize(smallSegmentSize))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	var pages []*Page
+	for i := 0; i < 6; i++ {
+		p, err := df.AllocatePage(PageTypeData)
+		if err != nil {
+			t.Fatalf("AllocatePage: %v", err)
+		}
+		pages = append(pages, p)
+	}
+
+	cutoff := pages[len(pages)-1].GetPageNum()
+	if err := df.TruncatePagesBefore(cutoff); err != nil {
+		t.Fatalf("TruncatePagesBefore: %v", err)
+	}
+
+	if _, err := os.Stat(segmentSuffix(path, 1)); !os.IsNotExist(err) {
+		t.Errorf("expected segment 1 to be removed, stat err: %v", err)
+	}
+
+	if _, err := df.readPage(pages[2].GetPageNum()); err != ErrPageNotFound {
+		t.Errorf("expected ErrPageNotFound reading a truncated page, got %v", err)
+	}
+
+	if _, err := df.readPage(cutoff); err != nil {
+		t.Errorf("expected the head segment's page to survive truncation, got %v", err)
+	}
+}
+
+func TestRangePagesStopsEarly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "range.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := df.AllocatePage(PageTypeData); err != nil {
+			t.Fatalf("AllocatePage: %v", err)
+		}
+	}
+
+	visited := 0
+	df.RangePages(func(p *Page) bool {
+		visited++
+		return visited < 2
+	})
+
+	if visited != 2 {
+		t.Errorf("expected RangePages to stop after 2 pages, visited %d", visited)
+	}
+}
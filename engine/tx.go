@@ -0,0 +1,287 @@
+package engine
+
+import "errors"
+
+// Errors returned by the transaction API.
+var (
+	// ErrTxReadOnlyFile mirrors the open-mode compatibility check in
+	// OpenFileReadOnly: a writable transaction cannot be started against
+	// a file that was never opened for writing.
+	ErrTxReadOnlyFile = errors.New("cannot begin writable transaction: file was opened read-only")
+
+	ErrTxReadOnly = errors.New("transaction is read-only")
+	ErrTxClosed   = errors.New("transaction already committed or rolled back")
+)
+
+// Tx is a transaction against a DBFile. Writable transactions see a
+// stable snapshot of the file and copy-on-write any page they allocate:
+// nothing is visible to other transactions until Commit swaps the active
+// file header. Read-only transactions run concurrently against whichever
+// snapshot was active when they began, even while a writer is in
+// progress, because the writer never mutates a page in place.
+type Tx struct {
+	db       *DBFile
+	writable bool
+
+	snapshot  FileHeader       // Header as of Begin; defines this tx's view of the file
+	pages     map[uint32]*Page // COW pages written in this tx, keyed by page number
+	freed     []uint32         // Pages freed in this tx, not yet safe to reuse
+	nextAlloc uint32           // Next page number AllocatePage hands out once firstFree is empty
+	firstFree uint32           // Head of the on-disk free list AllocatePage has not yet consumed
+	done      bool
+}
+
+// Begin starts a new transaction. Writable transactions are single-writer
+// and block until any other writable transaction commits or rolls back;
+// read transactions never block and always see a consistent snapshot.
+func (db *DBFile) Begin(writable bool) (*Tx, error) {
+	if writable {
+		if db.readOnly {
+			return nil, ErrTxReadOnlyFile
+		}
+		db.writeMu.Lock()
+	}
+
+	db.mutex.RLock()
+	snapshot := db.header
+	db.mutex.RUnlock()
+
+	db.snapMu.Lock()
+	db.openSnapshots[snapshot.Generation]++
+	db.snapMu.Unlock()
+
+	return &Tx{
+		db:        db,
+		writable:  writable,
+		snapshot:  snapshot,
+		pages:     make(map[uint32]*Page),
+		nextAlloc: snapshot.PageCount,
+		firstFree: snapshot.FirstFree,
+	}, nil
+}
+
+// GetPage returns the page for pageNum as seen by this transaction: a
+// page allocated earlier in this same (writable) transaction, or the
+// page as it was written on disk at the time this transaction began.
+func (tx *Tx) GetPage(pageNum uint32) (*Page, error) {
+	if tx.done {
+		return nil, ErrTxClosed
+	}
+
+	if page, ok := tx.pages[pageNum]; ok {
+		return page, nil
+	}
+
+	if pageNum >= tx.snapshot.PageCount {
+		return nil, ErrPageNotFound
+	}
+
+	return tx.db.readPage(pageNum)
+}
+
+// AllocatePage hands out a page number and keeps the page entirely in
+// memory until Commit. It reuses the head of the on-disk free list
+// (tx.firstFree, seeded from the snapshot's FirstFree at Begin) before
+// growing the file: since a writable Tx holds db.writeMu for its whole
+// lifetime, nothing else can consume or extend that list concurrently,
+// and every page already on it was freed by a commit old enough that no
+// open snapshot can still see its prior contents (mergeReclaimableFreePages
+// only links a page in once that holds). Only once the free list is
+// empty does this hand out a fresh page number beyond the snapshot,
+// which by construction no other transaction can observe until this one
+// commits.
+func (tx *Tx) AllocatePage(pageType PageType) (*Page, error) {
+	if !tx.writable {
+		return nil, ErrTxReadOnly
+	}
+	if tx.done {
+		return nil, ErrTxClosed
+	}
+
+	if tx.firstFree != 0 {
+		pageNum := tx.firstFree
+
+		freePage, err := tx.db.readPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+		tx.firstFree = freePage.header.NextPage
+
+		page := NewPage(pageNum, pageType)
+		tx.pages[pageNum] = page
+		return page, nil
+	}
+
+	pageNum := tx.nextAlloc
+	tx.nextAlloc++
+
+	page := NewPage(pageNum, pageType)
+	tx.pages[pageNum] = page
+	return page, nil
+}
+
+// RootPage returns the root page number as seen by this transaction: the
+// value SetRootPage last set in this tx, or the value as of Begin.
+func (tx *Tx) RootPage() uint32 {
+	return tx.snapshot.RootPage
+}
+
+// SetRootPage records a new root page number to take effect on Commit.
+// Used by index structures (e.g. the B+Tree) that relocate their root as
+// part of a writable transaction.
+func (tx *Tx) SetRootPage(pageNum uint32) {
+	tx.snapshot.RootPage = pageNum
+}
+
+// FreePage marks pageNum as no longer needed by this transaction. The
+// page is not handed back to the global free list immediately: it stays
+// reserved until every read snapshot that could still see its old
+// contents has closed, so a concurrent reader begun before this commit
+// never reads a reused page.
+func (tx *Tx) FreePage(pageNum uint32) error {
+	if !tx.writable {
+		return ErrTxReadOnly
+	}
+	if tx.done {
+		return ErrTxClosed
+	}
+
+	tx.freed = append(tx.freed, pageNum)
+	return nil
+}
+
+// Commit makes a writable transaction's pages visible by writing them to
+// disk and then swapping the active file header onto the new PageCount,
+// RootPage and FirstFree. Pages this transaction freed are queued for
+// reuse and merged into the real free list as soon as no open snapshot
+// still needs them; pages this transaction allocated off that same free
+// list are reflected here by FirstFree having already moved past them.
+// Commit on a read-only transaction simply releases its snapshot.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxClosed
+	}
+	defer tx.release()
+
+	if !tx.writable {
+		tx.done = true
+		return nil
+	}
+
+	// PageCount must be bumped to tx.nextAlloc before any of this tx's
+	// pages are written: writePagePinnedLocked rejects a page number
+	// that isn't yet below PageCount, and every page this tx allocated
+	// is by definition >= the snapshot's (stale) PageCount. So this runs
+	// as one critical section, the same way AllocatePage bumps
+	// PageCount and writes the new page under a single df.mutex hold,
+	// rather than writing pages first and swapping the header after.
+	tx.db.mutex.Lock()
+	tx.db.header.PageCount = tx.nextAlloc
+	tx.db.header.RootPage = tx.snapshot.RootPage
+	tx.db.header.FirstFree = tx.firstFree
+
+	for _, page := range tx.pages {
+		if err := tx.db.writePagePinnedLocked(page, false); err != nil {
+			tx.db.mutex.Unlock()
+			return err
+		}
+	}
+
+	err := tx.db.writeHeader()
+	if err == nil {
+		err = tx.db.remapLocked()
+	}
+	tx.db.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tx.db.snapMu.Lock()
+	if len(tx.freed) > 0 {
+		tx.db.pendingFree[tx.snapshot.Generation] = append(tx.db.pendingFree[tx.snapshot.Generation], tx.freed...)
+	}
+	tx.db.snapMu.Unlock()
+
+	tx.done = true
+	return nil
+}
+
+// Rollback discards every page this transaction allocated and every free
+// it queued. Since COW pages are never written until Commit, rolling
+// back a writable transaction writes none of its own pages; releasing it
+// may still merge free pages queued by earlier commits into the on-disk
+// free list.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxClosed
+	}
+
+	tx.release()
+	tx.done = true
+	return nil
+}
+
+// release drops this transaction's snapshot refcount, merges any now-safe
+// free pages into the global free list, and releases the writer lock.
+func (tx *Tx) release() {
+	db := tx.db
+
+	db.snapMu.Lock()
+	db.openSnapshots[tx.snapshot.Generation]--
+	if db.openSnapshots[tx.snapshot.Generation] == 0 {
+		delete(db.openSnapshots, tx.snapshot.Generation)
+	}
+	db.snapMu.Unlock()
+
+	if tx.writable {
+		db.mergeReclaimableFreePages()
+		db.writeMu.Unlock()
+	}
+}
+
+// mergeReclaimableFreePages moves pages freed by past commits into the
+// real free list, but only for generations older than every snapshot
+// still open: those are the only pages guaranteed invisible to every
+// live reader.
+func (db *DBFile) mergeReclaimableFreePages() {
+	db.snapMu.Lock()
+	var oldestOpen uint32
+	hasOpenSnapshot := false
+	for gen := range db.openSnapshots {
+		if !hasOpenSnapshot || gen < oldestOpen {
+			oldestOpen = gen
+			hasOpenSnapshot = true
+		}
+	}
+
+	var reclaimable []uint32
+	for gen, pages := range db.pendingFree {
+		if hasOpenSnapshot && gen >= oldestOpen {
+			continue
+		}
+		reclaimable = append(reclaimable, pages...)
+		delete(db.pendingFree, gen)
+	}
+	db.snapMu.Unlock()
+
+	if len(reclaimable) == 0 {
+		return
+	}
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for _, pageNum := range reclaimable {
+		freePage := NewPage(pageNum, PageTypeData)
+		freePage.header.NextPage = db.header.FirstFree
+		db.header.FirstFree = pageNum
+
+		// Uses the Locked variant since this function already holds db.mutex.
+		if err := db.writePagePinnedLocked(freePage, false); err != nil {
+			// Best effort: leave the page out of the free list rather
+			// than corrupt it; it will simply never be reused.
+			continue
+		}
+	}
+	db.writeHeader()
+}
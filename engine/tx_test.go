@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTxCommitIsolatesConcurrentSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tx.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	reader, err := df.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin(false): %v", err)
+	}
+
+	writer, err := df.Begin(true)
+	if err != nil {
+		t.Fatalf("Begin(true): %v", err)
+	}
+
+	page, err := writer.AllocatePage(PageTypeData)
+	if err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	if _, err := reader.GetPage(page.GetPageNum()); err != ErrPageNotFound {
+		t.Errorf("expected reader snapshot to miss page committed after Begin, got %v", err)
+	}
+	if err := reader.Commit(); err != nil {
+		t.Fatalf("Commit reader: %v", err)
+	}
+
+	after, err := df.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin(false) after commit: %v", err)
+	}
+	defer after.Commit()
+
+	if _, err := after.GetPage(page.GetPageNum()); err != nil {
+		t.Errorf("expected new snapshot to see committed page, got %v", err)
+	}
+}
+
+func TestTxBeginWritableRejectsReadOnlyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "readonly.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	if err := df.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	ro, err := OpenFileReadOnly(path)
+	if err != nil {
+		t.Fatalf("OpenFileReadOnly: %v", err)
+	}
+	defer ro.Close()
+
+	if _, err := ro.Begin(true); err != ErrTxReadOnlyFile {
+		t.Errorf("expected ErrTxReadOnlyFile, got %v", err)
+	}
+}
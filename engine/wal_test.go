@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALRecoversAfterTornTail(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crash.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+
+	page, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+	if err := page.WriteData(0, []byte("hello")); err != nil {
+		t.Fatalf("WriteData: %v", err)
+	}
+	if err := df.writePage(page); err != nil {
+		t.Fatalf("writePage: %v", err)
+	}
+
+	if err := df.file.Close(); err != nil {
+		t.Fatalf("close main file: %v", err)
+	}
+	if err := df.wal.close(); err != nil {
+		t.Fatalf("close wal: %v", err)
+	}
+
+	// Simulate a crash mid-write: truncate the WAL so its last record is
+	// torn (shorter than a full record).
+	walPath := path + walSuffix
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatalf("stat wal: %v", err)
+	}
+	if err := os.Truncate(walPath, info.Size()-10); err != nil {
+		t.Fatalf("truncate wal: %v", err)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile after crash: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.header.MagicNumber != DBFileMagicNumber {
+		t.Errorf("expected file to reopen consistently, got invalid header")
+	}
+}
+
+// TestWALRecoversBufferedWriteNeverFlushedToSegment guards against
+// CheckpointLSN being advanced by writePagePinnedLocked itself: with a
+// buffer pool configured, a page write only reaches its segment on
+// eviction or Flush/Checkpoint, so until one of those happens the only
+// durable copy of the write is the WAL record. If CheckpointLSN had
+// already moved past that record's LSN, replay would skip it and the
+// write would be lost on reopen.
+func TestWALRecoversBufferedWriteNeverFlushedToSegment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "buffered-crash.db")
+
+	df, err := CreateFile(path, WithBufferPool(64))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+
+	page, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+	if err := page.WriteData(0, []byte("buffered")); err != nil {
+		t.Fatalf("WriteData: %v", err)
+	}
+	if err := df.writePage(page); err != nil {
+		t.Fatalf("writePage: %v", err)
+	}
+
+	// Crash before the pool's deferred write-back (no Flush/Checkpoint,
+	// no Close) ever reaches the segment file.
+	if err := df.file.Close(); err != nil {
+		t.Fatalf("close main file: %v", err)
+	}
+	if err := df.wal.close(); err != nil {
+		t.Fatalf("close wal: %v", err)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile after crash: %v", err)
+	}
+	defer reopened.Close()
+
+	got, err := reopened.readPageFromDisk(page.GetPageNum())
+	if err != nil {
+		t.Fatalf("readPageFromDisk: %v", err)
+	}
+	data, err := got.ReadData(0, 8)
+	if err != nil {
+		t.Fatalf("ReadData: %v", err)
+	}
+	if string(data) != "buffered" {
+		t.Errorf("expected replay to recover %q, got %q", "buffered", data)
+	}
+}
+
+// TestCheckpointBeforeAnyAppendDoesNotSkipFutureRecords guards against
+// checkpoint computing CheckpointLSN as nextLSN-1 unconditionally:
+// calling Checkpoint before a single WAL record has ever been
+// appended leaves nextLSN at 0, and the subtraction used to underflow to
+// math.MaxUint64. Every future record's LSN is always <= MaxUint64, so
+// replay's "rec.LSN <= CheckpointLSN" check would then skip every record
+// forever, silently discarding crash recovery for the rest of the file's
+// life.
+func TestCheckpointBeforeAnyAppendDoesNotSkipFutureRecords(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "empty-checkpoint.db")
+
+	df, err := CreateFile(path, WithBufferPool(64))
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+
+	// Checkpoint right after CreateFile, before any page has ever been
+	// allocated or written, so nothing is in the WAL yet.
+	if err := df.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+
+	page, err := df.AllocatePage(PageTypeData)
+	if err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+	if err := page.WriteData(0, []byte("post-checkpoint")); err != nil {
+		t.Fatalf("WriteData: %v", err)
+	}
+	if err := df.writePage(page); err != nil {
+		t.Fatalf("writePage: %v", err)
+	}
+
+	// Crash before the pool's deferred write-back, or another
+	// Flush/Checkpoint, ever reaches the segment file: the WAL record is
+	// the only durable copy of this write.
+	if err := df.file.Close(); err != nil {
+		t.Fatalf("close main file: %v", err)
+	}
+	if err := df.wal.close(); err != nil {
+		t.Fatalf("close wal: %v", err)
+	}
+
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile after crash: %v", err)
+	}
+	defer reopened.Close()
+
+	got, err := reopened.readPageFromDisk(page.GetPageNum())
+	if err != nil {
+		t.Fatalf("readPageFromDisk: %v", err)
+	}
+	data, err := got.ReadData(0, uint16(len("post-checkpoint")))
+	if err != nil {
+		t.Fatalf("ReadData: %v", err)
+	}
+	if string(data) != "post-checkpoint" {
+		t.Errorf("expected replay to recover %q, got %q", "post-checkpoint", data)
+	}
+}
+
+func TestCheckpointTruncatesWAL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "checkpoint.db")
+
+	df, err := CreateFile(path)
+	if err != nil {
+		t.Fatalf("CreateFile: %v", err)
+	}
+	defer df.Close()
+
+	if _, err := df.AllocatePage(PageTypeData); err != nil {
+		t.Fatalf("AllocatePage: %v", err)
+	}
+
+	if err := df.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+
+	info, err := df.wal.file.Stat()
+	if err != nil {
+		t.Fatalf("stat wal: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("expected WAL to be truncated after checkpoint, got size %d", info.Size())
+	}
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"os"
+
+	"github.com/Rudra-Sankha-Sinhamahapatra/Anon-DB/engine"
+	"github.com/Rudra-Sankha-Sinhamahapatra/Anon-DB/engine/btree"
+)
+
+// DiskStore is a persistent DataStorer[string, []byte] backed by a
+// page-engine B+Tree, for callers that need data to survive a restart
+// instead of InMemoryStore's in-process map.
+type DiskStore struct {
+	df   *engine.DBFile
+	tree *btree.BTree
+}
+
+// NewDiskStore opens the database file at path, creating it if it
+// doesn't already exist, and returns a DiskStore backed by its B+Tree
+// index.
+func NewDiskStore(path string) (*DiskStore, error) {
+	df, err := engine.OpenFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		df, err = engine.CreateFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := btree.Open(df)
+	if err != nil {
+		df.Close()
+		return nil, err
+	}
+
+	return &DiskStore{df: df, tree: tree}, nil
+}
+
+// Get returns the value stored under key, or Error if it isn't present.
+func (s *DiskStore) Get(key string) ([]byte, error) {
+	value, err := s.tree.Get([]byte(key))
+	if errors.Is(err, btree.ErrKeyNotFound) {
+		return nil, Error
+	}
+	if err != nil {
+		panic(err)
+	}
+	return value, nil
+}
+
+// Set stores value under key, overwriting any existing value. DataStorer
+// gives Set no error return, so a failure writing to disk is not
+// recoverable by the caller; it panics rather than silently losing data.
+func (s *DiskStore) Set(key string, Value []byte) {
+	if err := s.tree.Put([]byte(key), Value); err != nil {
+		panic(err)
+	}
+}
+
+// Delete removes key. Deleting a key that isn't present is a no-op, same
+// as InMemoryStore.
+func (s *DiskStore) Delete(key string) {
+	if err := s.tree.Delete([]byte(key)); err != nil && !errors.Is(err, btree.ErrKeyNotFound) {
+		panic(err)
+	}
+}
+
+// Close releases the underlying database file.
+func (s *DiskStore) Close() error {
+	return s.df.Close()
+}
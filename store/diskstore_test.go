@@ -0,0 +1,52 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskStoreSetGetRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskStore(filepath.Join(dir, "disk.db"))
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	defer s.Close()
+
+	s.Set("hello", []byte("bar"))
+	value, err := s.Get("hello")
+	if err != nil {
+		t.Errorf("Expected nil, got %v", err)
+	}
+	if string(value) != "bar" {
+		t.Errorf("Expected bar, got %v", value)
+	}
+}
+
+func TestDiskStoreGetMissingKeyReturnsError(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskStore(filepath.Join(dir, "disk.db"))
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.Get("missing"); err != Error {
+		t.Errorf("Expected %v, got %v", Error, err)
+	}
+}
+
+func TestDiskStoreDeleteRemovesKey(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskStore(filepath.Join(dir, "disk.db"))
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	defer s.Close()
+
+	s.Set("gone", []byte("soon"))
+	s.Delete("gone")
+	if _, err := s.Get("gone"); err != Error {
+		t.Errorf("Expected %v, got %v", Error, err)
+	}
+}
@@ -1,5 +1,11 @@
 package store
 
+import "errors"
+
+// Error is returned by a DataStorer's Get when the requested key isn't
+// present, regardless of which backing implementation is in use.
+var Error = errors.New("store: key not found")
+
 type DataStorer[K comparable, V any] interface {
 	Get(key K) (V, error)
 	Set(key K, Value V)